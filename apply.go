@@ -0,0 +1,354 @@
+package dockertest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/go-connections/nat"
+)
+
+const defaultWaitTimeout = time.Minute
+
+// specEnvironment holds the networks and volumes created for a Spec.
+type specEnvironment struct {
+	networks map[string]*Network
+	volumes  map[string]string
+}
+
+// Apply creates all networks, volumes and containers of the spec and starts the containers in dependency order,
+// independent containers are started in parallel. After starting a container, Apply waits as described by its
+// WaitSpec before it starts the containers depending on it.
+// The created containers are returned by their name in the spec, also if an error occurred.
+func (dt *Session) Apply(ctx context.Context, spec *Spec) (map[string]*Container, error) {
+	containers, ordered, err := dt.buildSpec(ctx, spec)
+	if err != nil {
+		return containers, err
+	}
+
+	return containers, dt.startGraph(ctx, ordered)
+}
+
+// RunSpec creates and starts the environment of the spec like Apply, but holds back the containers named as Test.
+// Once all other containers are ready, the test container is run to completion like by Session.Run,
+// its output is streamed to the given writers. A non-zero exit code is returned as *ExitError.
+func (dt *Session) RunSpec(ctx context.Context, spec *Spec, output ...io.Writer) (map[string]*Container, RunResult, error) {
+	if spec.Test == "" {
+		return map[string]*Container{}, RunResult{ExitCode: -1}, fmt.Errorf("%w: no test container", ErrInvalidSpec)
+	}
+
+	containers, ordered, err := dt.buildSpec(ctx, spec)
+	if err != nil {
+		return containers, RunResult{ExitCode: -1}, err
+	}
+
+	test := containers[spec.Test]
+	environment := make([]*Container, 0, len(ordered))
+
+	for _, c := range ordered {
+		if c != test {
+			environment = append(environment, c)
+		}
+	}
+
+	err = dt.startGraph(ctx, environment)
+	if err != nil {
+		return containers, RunResult{ExitCode: -1}, err
+	}
+
+	result, err := dt.runContainer(ctx, test, output)
+
+	return containers, result, err
+}
+
+// buildSpec creates the networks, volumes and containers of the spec,
+// the containers are also returned in start order.
+func (dt *Session) buildSpec(ctx context.Context, spec *Spec) (map[string]*Container, []*Container, error) {
+	containers := map[string]*Container{}
+
+	order, err := spec.startOrder()
+	if err != nil {
+		return containers, nil, err
+	}
+
+	env, err := dt.createSpecEnvironment(ctx, spec)
+	if err != nil {
+		return containers, nil, err
+	}
+
+	templates := map[string]*ContainerBuilder{}
+	ordered := make([]*Container, 0, len(order))
+
+	for _, name := range order {
+		b, err := dt.specContainerBuilder(ctx, spec, env, templates, name, spec.Containers[name])
+		if err != nil {
+			return containers, nil, err
+		}
+
+		for _, dependency := range spec.Containers[name].DependsOn {
+			b.DependsOn(containers[dependency], ConditionStarted())
+		}
+
+		containers[name], err = b.ReadyWhen(waitConditions(spec.Containers[name].Wait)...).BuildCtx(ctx)
+		if err != nil {
+			return containers, nil, fmt.Errorf("error creating container '%s': %w", name, err)
+		}
+
+		ordered = append(ordered, containers[name])
+	}
+
+	return containers, ordered, nil
+}
+
+func (dt *Session) createSpecEnvironment(ctx context.Context, spec *Spec) (specEnvironment, error) {
+	env := specEnvironment{networks: map[string]*Network{}, volumes: map[string]string{}}
+
+	for _, name := range sortedKeys(spec.Networks) {
+		n, err := dt.specNetworkBuilder(name, spec.Networks[name]).CreateCtx(ctx)
+		if err != nil {
+			return env, fmt.Errorf("error creating network '%s': %w", name, err)
+		}
+
+		env.networks[name] = n
+	}
+
+	for _, name := range sortedKeys(spec.Volumes) {
+		v, err := dt.dockerClient.VolumeCreate(ctx, volume.CreateOptions{
+			Name:   fmt.Sprintf("%s-%s", name, dt.ID),
+			Driver: spec.Volumes[name].Driver,
+			Labels: dt.getLabels(),
+		})
+		if err != nil {
+			return env, fmt.Errorf("error creating volume '%s': %w", name, err)
+		}
+
+		env.volumes[name] = v.Name
+	}
+
+	return env, nil
+}
+
+func (dt *Session) specNetworkBuilder(name string, spec NetworkSpec) NetworkBuilder {
+	b := dt.newNetworkBuilder(fmt.Sprintf("%s-%s", name, dt.ID))
+
+	if spec.Subnet != "" {
+		b = b.subnet(spec.Subnet, spec.IPRange)
+	}
+
+	if spec.AutoSubnet {
+		b = b.AutoSubnet()
+	}
+
+	if spec.Internal {
+		b = b.Internal()
+	}
+
+	return b
+}
+
+// specContainerBuilder returns a builder configured by the given container spec.
+// Builders of templates are created once and copied for every container extending them.
+func (dt *Session) specContainerBuilder(
+	ctx context.Context,
+	spec *Spec,
+	env specEnvironment,
+	templates map[string]*ContainerBuilder,
+	name string,
+	c ContainerSpec,
+) (*ContainerBuilder, error) {
+	var b *ContainerBuilder
+
+	if c.Extends == "" {
+		b = dt.NewContainerBuilder()
+	} else {
+		template, err := dt.specTemplateBuilder(spec, env, templates, c.Extends, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		b = template.NewContainerBuilder()
+	}
+
+	b.Name(name)
+	applyContainerSpec(b, spec, env, c)
+
+	if c.Build != nil {
+		tag := strings.ToLower(fmt.Sprintf("%s-%s:%s", dt.mainLabel, name, dt.ID))
+
+		err := buildImage(ctx, dt.dockerClient, spec.resolvePath(c.Build.Context), tag, *c.Build, dt.getLabels())
+		if err != nil {
+			return nil, fmt.Errorf("error building image for '%s': %w", name, err)
+		}
+
+		b.Image(tag)
+	}
+
+	// make the container reachable by its name in all networks, including the ones inherited from templates.
+	for _, n := range env.networks {
+		if _, ok := b.NetworkingConfig.EndpointsConfig[n.NetworkName]; ok {
+			b.NetworkAliases(n, name)
+		}
+	}
+
+	return b, nil
+}
+
+func (dt *Session) specTemplateBuilder(
+	spec *Spec,
+	env specEnvironment,
+	templates map[string]*ContainerBuilder,
+	name string,
+	path []string,
+) (*ContainerBuilder, error) {
+	if b, ok := templates[name]; ok {
+		return b, nil
+	}
+
+	for _, p := range path {
+		if p == name {
+			return nil, fmt.Errorf("%w: template cycle %s -> %s", ErrInvalidSpec, strings.Join(path, " -> "), name)
+		}
+	}
+
+	t := spec.Templates[name]
+
+	b := dt.NewContainerBuilder()
+
+	if t.Extends != "" {
+		parent, err := dt.specTemplateBuilder(spec, env, templates, t.Extends, append(path, name))
+		if err != nil {
+			return nil, err
+		}
+
+		b = parent.NewContainerBuilder()
+	}
+
+	applyContainerSpec(b, spec, env, t)
+	templates[name] = b
+
+	return b, nil
+}
+
+func applyContainerSpec(b *ContainerBuilder, spec *Spec, env specEnvironment, c ContainerSpec) {
+	if c.Image != "" {
+		b.Image(c.Image)
+	}
+
+	if len(c.Cmd) > 0 {
+		b.CmdArgs(c.Cmd...)
+	}
+
+	if c.WorkingDir != "" {
+		b.WorkingDir(c.WorkingDir)
+	}
+
+	for _, k := range sortedKeys(c.Env) {
+		b.Env(k, c.Env[k])
+	}
+
+	for _, port := range c.Expose {
+		b.ExposePort(port)
+	}
+
+	for _, port := range c.Ports {
+		// the ports are validated with the spec.
+		mappings, _ := nat.ParsePortSpec(port)
+		for _, mapping := range mappings {
+			b.ExposePort(string(mapping.Port)).addPortBinding(mapping.Port, mapping.Binding)
+		}
+	}
+
+	for _, m := range c.Mounts {
+		b.Mount(specMount(spec, env, m))
+	}
+
+	for _, n := range c.Networks {
+		b.Connect(env.networks[n])
+	}
+
+	for _, n := range sortedKeys(c.Aliases) {
+		b.NetworkAliases(env.networks[n], c.Aliases[n]...)
+	}
+
+	applyHealthSpec(b, c.Health)
+}
+
+// specMount splits a mount into source and target, resolving the source to a session volume or an absolute path.
+func specMount(spec *Spec, env specEnvironment, mount string) (string, string) {
+	source, target, _ := strings.Cut(mount, ":")
+
+	if v, ok := env.volumes[source]; ok {
+		return v, target
+	}
+
+	return spec.resolvePath(source), target
+}
+
+func applyHealthSpec(b *ContainerBuilder, h *HealthSpec) {
+	if h == nil {
+		return
+	}
+
+	if h.Disable {
+		b.HealthDisable()
+
+		return
+	}
+
+	switch {
+	case len(h.Test) > 0:
+		b.ensureHealth()
+		b.ContainerConfig.Healthcheck.Test = h.Test
+	case h.Cmd != "":
+		b.HealthCmd(h.Cmd)
+	case h.ShellCmd != "":
+		b.HealthShellCmd(h.ShellCmd)
+	}
+
+	if h.StartPeriod > 0 {
+		b.HealthStartPeriod(h.StartPeriod)
+	}
+
+	if h.Interval > 0 {
+		b.HealthInterval(h.Interval)
+	}
+
+	if h.Timeout > 0 {
+		b.HealthTimeout(h.Timeout)
+	}
+
+	if h.Retries > 0 {
+		b.HealthRetries(h.Retries)
+	}
+}
+
+// waitConditions translates a WaitSpec into the conditions a container must meet to be ready.
+func waitConditions(wait *WaitSpec) []Condition {
+	if wait == nil {
+		return nil
+	}
+
+	timeout := wait.Timeout
+	if timeout == 0 {
+		timeout = defaultWaitTimeout
+	}
+
+	var conditions []Condition
+
+	if wait.Healthy {
+		conditions = append(conditions, ConditionHealthy().WithTimeout(timeout))
+	}
+
+	if wait.Log != "" {
+		conditions = append(conditions, ConditionLogContains(wait.Log).WithTimeout(timeout))
+	}
+
+	if wait.Exit {
+		conditions = append(conditions, ConditionExitedSuccessfully().WithTimeout(timeout))
+	}
+
+	return conditions
+}
@@ -0,0 +1,286 @@
+package dockertest
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const artifactDirPerm = 0755
+
+// ArtifactManifest indexes the files written by Session.CollectArtifacts.
+type ArtifactManifest struct {
+	SessionID   string     `json:"sessionId"`
+	CollectedAt time.Time  `json:"collectedAt"`
+	Files       []Artifact `json:"files"`
+	// Errors lists the artifacts that could not be collected.
+	Errors []string `json:"errors"`
+	// Dir is the directory the artifacts were written to.
+	Dir string `json:"-"`
+}
+
+// Artifact is a single file of an artifact bundle.
+type Artifact struct {
+	// Kind is one of stdout, stderr, inspect, health, diff, stats, events or network.
+	Kind string `json:"kind"`
+	// Container is the name of the container the artifact belongs to, it is empty for session wide artifacts.
+	Container string `json:"container,omitempty"`
+	// Path is relative to the manifests directory.
+	Path string `json:"path"`
+}
+
+// CollectArtifacts gathers diagnostics of all containers of the session into a new directory per session below dir:
+// logs split by stream and with timestamps, the inspect result, the health check log, the filesystem changes and
+// the resource usage of every container, including the samples of Container.SampleStats, as well as the sessions
+// docker events and the inspect results of its networks.
+// A manifest.json indexes all files. Artifacts that cannot be collected are recorded in the manifests Errors.
+func (dt *Session) CollectArtifacts(ctx context.Context, dir string) (*ArtifactManifest, error) {
+	sessionDir, err := newArtifactDir(dir, dt.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &ArtifactManifest{SessionID: dt.ID, CollectedAt: time.Now(), Dir: sessionDir}
+
+	containers, err := dt.dockerClient.ContainerList(ctx, types.ContainerListOptions{
+		All:     true,
+		Filters: dt.sessionFilterArgs(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range containers {
+		dt.collectContainerArtifacts(ctx, m, c)
+	}
+
+	dt.collectNetworkArtifacts(ctx, m)
+	eventsJSON, err := dt.sessionEvents(ctx)
+	m.add("events", "", "events.json", eventsJSON, err)
+
+	return m, m.write()
+}
+
+// Archive packs the collected artifacts into a .tar.gz file at the given path.
+func (m *ArtifactManifest) Archive(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(f)
+
+	err = writeTar(gz, m.Dir)
+	if err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	err = gz.Close()
+	if err != nil {
+		_ = f.Close()
+
+		return err
+	}
+
+	return f.Close()
+}
+
+// newArtifactDir creates a directory for the session which does not overwrite artifacts of previous runs.
+func newArtifactDir(dir, sessionID string) (string, error) {
+	for i := 1; ; i++ {
+		name := sessionID
+		if i > 1 {
+			name = fmt.Sprintf("%s-%v", sessionID, i)
+		}
+
+		sessionDir := filepath.Join(dir, name)
+
+		err := os.MkdirAll(dir, artifactDirPerm)
+		if err != nil {
+			return "", err
+		}
+
+		err = os.Mkdir(sessionDir, artifactDirPerm)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+
+		return sessionDir, err
+	}
+}
+
+func (dt *Session) collectContainerArtifacts(ctx context.Context, m *ArtifactManifest, c types.Container) {
+	name := strings.TrimPrefix(c.Names[0], "/")
+	sessionContainer := &Container{Name: name, containerID: c.ID}
+	dir := filepath.Join("containers", name)
+
+	stdout, stderr, err := dt.containerLogStreams(ctx, sessionContainer)
+	m.add("stdout", name, filepath.Join(dir, "stdout.log"), stdout, err)
+	m.add("stderr", name, filepath.Join(dir, "stderr.log"), stderr, err)
+
+	inspectResult, err := dt.dockerClient.ContainerInspect(ctx, c.ID)
+	inspectJSON, err := marshalArtifact(inspectResult, err)
+	m.add("inspect", name, filepath.Join(dir, "inspect.json"), inspectJSON, err)
+
+	if inspectResult.State != nil && inspectResult.State.Health != nil {
+		healthLog, err := getContainerHealthCheckLog(ctx, dt.dockerClient, sessionContainer)
+		m.add("health", name, filepath.Join(dir, "health.txt"), healthLog, err)
+	}
+
+	changes, err := dt.dockerClient.ContainerDiff(ctx, c.ID)
+	m.add("diff", name, filepath.Join(dir, "diff.txt"), formatChanges(changes), err)
+
+	if c.State == "running" {
+		statsJSON, err := dt.containerStatsJSON(ctx, c.ID)
+		m.add("stats", name, filepath.Join(dir, "stats.json"), statsJSON, err)
+	}
+
+	if samples := dt.containerStatsSamples(c.ID); len(samples) > 0 {
+		statsCSV, err := formatStatsCSV(samples)
+		m.add("stats", name, filepath.Join(dir, "stats.csv"), statsCSV, err)
+	}
+}
+
+func (dt *Session) collectNetworkArtifacts(ctx context.Context, m *ArtifactManifest) {
+	networks, err := dt.dockerClient.NetworkList(ctx, types.NetworkListOptions{
+		Filters: dt.sessionFilterArgs(),
+	})
+	if err != nil {
+		m.Errors = append(m.Errors, fmt.Sprintf("networks: %v", err))
+
+		return
+	}
+
+	for _, n := range networks {
+		networkResource, err := dt.dockerClient.NetworkInspect(ctx, n.ID, types.NetworkInspectOptions{Verbose: true})
+		networkJSON, err := marshalArtifact(networkResource, err)
+		m.add("network", "", filepath.Join("networks", n.Name+".json"), networkJSON, err)
+	}
+}
+
+// containerLogStreams returns the containers stdout and stderr log with timestamps.
+func (dt *Session) containerLogStreams(ctx context.Context, c *Container) ([]byte, []byte, error) {
+	logReader, err := dt.dockerClient.ContainerLogs(ctx, c.containerID, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Timestamps: true,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w for '%s': %w", ErrReadingContainerLog, c.Name, err)
+	}
+
+	defer func() {
+		_ = logReader.Close()
+	}()
+
+	var stdout, stderr strings.Builder
+
+	_, err = stdcopy.StdCopy(&stdout, &stderr, logReader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w stream for '%s': %w", ErrReadingContainerLog, c.Name, err)
+	}
+
+	return []byte(stdout.String()), []byte(stderr.String()), nil
+}
+
+func (dt *Session) containerStatsJSON(ctx context.Context, containerID string) ([]byte, error) {
+	stats, err := dt.dockerClient.ContainerStats(ctx, containerID, false)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		_ = stats.Body.Close()
+	}()
+
+	var v types.StatsJSON
+
+	err = json.NewDecoder(stats.Body).Decode(&v)
+
+	return marshalArtifact(v, err)
+}
+
+// sessionEvents returns the docker events of the sessions components since the session was created.
+func (dt *Session) sessionEvents(ctx context.Context) ([]byte, error) {
+	messages, errs := dt.dockerClient.Events(ctx, types.EventsOptions{
+		Since:   strconv.FormatInt(dt.createdAt.Unix(), 10),
+		Until:   strconv.FormatInt(time.Now().Unix(), 10),
+		Filters: dt.sessionFilterArgs(),
+	})
+
+	var collected []events.Message
+
+	for {
+		select {
+		case msg := <-messages:
+			collected = append(collected, msg)
+		case err := <-errs:
+			if errors.Is(err, io.EOF) {
+				return marshalArtifact(collected, nil)
+			}
+
+			return nil, err
+		}
+	}
+}
+
+func formatChanges(changes []container.FilesystemChange) []byte {
+	var sb strings.Builder
+
+	for _, change := range changes {
+		sb.WriteString(fmt.Sprintf("%s %s\n", change.Kind, change.Path))
+	}
+
+	return []byte(sb.String())
+}
+
+func marshalArtifact(v interface{}, err error) ([]byte, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// add writes the artifact and adds it to the manifest, or records the error if it could not be collected.
+func (m *ArtifactManifest) add(kind, container, path string, content []byte, err error) {
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(m.Dir, filepath.Dir(path)), artifactDirPerm)
+	}
+
+	if err == nil {
+		err = os.WriteFile(filepath.Join(m.Dir, path), content, dumpFileMask)
+	}
+
+	if err != nil {
+		m.Errors = append(m.Errors, fmt.Sprintf("%s: %v", path, err))
+
+		return
+	}
+
+	m.Files = append(m.Files, Artifact{Kind: kind, Container: container, Path: filepath.ToSlash(path)})
+}
+
+func (m *ArtifactManifest) write() error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(m.Dir, "manifest.json"), b, dumpFileMask)
+}
@@ -0,0 +1,130 @@
+package dockertest
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/jsonmessage"
+)
+
+// buildImage builds an image from the given context directory and tags it.
+func buildImage(
+	ctx context.Context,
+	dockerClient *client.Client,
+	contextDir string,
+	tag string,
+	spec BuildSpec,
+	labels map[string]string,
+) error {
+	buildContext, writer := io.Pipe()
+
+	go func() {
+		_ = writer.CloseWithError(writeTar(writer, contextDir))
+	}()
+
+	defer func() {
+		_ = buildContext.Close()
+	}()
+
+	args := map[string]*string{}
+
+	for k := range spec.Args {
+		v := spec.Args[k]
+		args[k] = &v
+	}
+
+	resp, err := dockerClient.ImageBuild(ctx, buildContext, types.ImageBuildOptions{
+		Tags:        []string{tag},
+		Dockerfile:  spec.Dockerfile,
+		BuildArgs:   args,
+		Target:      spec.Target,
+		Labels:      labels,
+		Remove:      true,
+		ForceRemove: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	return jsonmessage.DisplayJSONMessagesStream(resp.Body, io.Discard, 0, false, nil)
+}
+
+// writeTar writes the content of the given directory as tar archive.
+func writeTar(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil || rel == "." {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		return writeTarEntry(tw, path, filepath.ToSlash(rel), info)
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+func writeTarEntry(tw *tar.Writer, path, name string, info fs.FileInfo) error {
+	var link string
+
+	if info.Mode()&fs.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+
+		link = target
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+	if info.IsDir() && !strings.HasSuffix(header.Name, "/") {
+		header.Name += "/"
+	}
+
+	err = tw.WriteHeader(header)
+	if err != nil || !info.Mode().IsRegular() {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = f.Close()
+	}()
+
+	_, err = io.Copy(tw, f)
+
+	return err
+}
@@ -0,0 +1,434 @@
+package dockertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	dockerNetwork "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+// ErrNetem is returned if the tc netem helper container did not apply the requested rule.
+var ErrNetem = errors.New("error applying netem rule")
+
+const defaultNetemImage = "gaiadocker/iproute2"
+const defaultNetemInterface = "eth0"
+
+// Chaos disrupts containers and networks of a Session at runtime to test
+// how services behave when dependencies disappear or degrade.
+// All effects are reverted when the Session is cleaned up.
+type Chaos struct {
+	// NetemImage is the image of the privileged helper container running tc. It must contain the tc binary.
+	NetemImage string
+	labels     map[string]string
+	mu         sync.Mutex
+	effects    []chaosEffect
+	pauses     map[string]*chaosPause
+	clientEnabled
+}
+
+// NetemOptions describes the network degradation applied by Chaos.Netem.
+// Zero values are not applied.
+type NetemOptions struct {
+	// Interface is the network interface inside the container, defaults to eth0.
+	Interface string
+	// Delay is added to every outgoing packet.
+	Delay time.Duration
+	// Jitter varies the Delay, it is ignored if no Delay is set.
+	Jitter time.Duration
+	// Loss is the percentage of dropped packets, for example 5 for 5%.
+	Loss float64
+	// Rate limits the bandwidth, for example "1mbit".
+	Rate string
+}
+
+type chaosEffect struct {
+	key    string
+	revert func(ctx context.Context) error
+}
+
+// chaosPause unpauses a paused container when its timer fires.
+type chaosPause struct {
+	timer *time.Timer
+	until time.Time
+}
+
+// Chaos returns the Chaos helper of the Session.
+func (dt *Session) Chaos() *Chaos {
+	if dt.chaos == nil {
+		dt.chaos = &Chaos{
+			NetemImage:    defaultNetemImage,
+			labels:        dt.getLabels(),
+			clientEnabled: dt.clientEnabled,
+		}
+	}
+
+	return dt.chaos
+}
+
+// Disconnect detaches the container from the given Network until Reconnect is called.
+func (ch *Chaos) Disconnect(c *Container, n *Network) error {
+	return ch.DisconnectCtx(ch.ctx, c, n)
+}
+
+// DisconnectCtx is like Disconnect using the given context.
+func (ch *Chaos) DisconnectCtx(ctx context.Context, c *Container, n *Network) error {
+	endpoint, err := ch.endpointSettings(ctx, c, n)
+	if err != nil {
+		return err
+	}
+
+	err = ch.dockerClient.NetworkDisconnect(ctx, n.NetworkID, c.containerID, true)
+	if err != nil {
+		return err
+	}
+
+	ch.addEffect(networkEffectKey(c, n), func(ctx context.Context) error {
+		return ch.dockerClient.NetworkConnect(ctx, n.NetworkID, c.containerID, endpoint)
+	})
+
+	return nil
+}
+
+// Reconnect attaches a container that was disconnected by Disconnect to the given Network again.
+func (ch *Chaos) Reconnect(c *Container, n *Network) error {
+	return ch.ReconnectCtx(ch.ctx, c, n)
+}
+
+// ReconnectCtx is like Reconnect using the given context.
+func (ch *Chaos) ReconnectCtx(ctx context.Context, c *Container, n *Network) error {
+	return ch.revert(ctx, networkEffectKey(c, n))
+}
+
+// Pause freezes all processes of the container for the given duration.
+// The call returns immediately, the container is unpaused in background.
+// Pausing a paused container again extends the pause, if it would end later.
+func (ch *Chaos) Pause(c *Container, d time.Duration) error {
+	return ch.PauseCtx(ch.ctx, c, d)
+}
+
+// PauseCtx is like Pause, the context is used to pause the container. The container is unpaused
+// independently of it, so it does not stay paused if the context ends earlier.
+func (ch *Chaos) PauseCtx(ctx context.Context, c *Container, d time.Duration) error {
+	key := fmt.Sprintf("pause:%s", c.containerID)
+	if ch.extendPause(key, d) {
+		return nil
+	}
+
+	err := ch.dockerClient.ContainerPause(ctx, c.containerID)
+	if err != nil {
+		return err
+	}
+
+	ch.addEffect(key, func(ctx context.Context) error {
+		return ch.dockerClient.ContainerUnpause(ctx, c.containerID)
+	})
+
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	if ch.pauses == nil {
+		ch.pauses = map[string]*chaosPause{}
+	}
+
+	ch.pauses[key] = &chaosPause{
+		until: time.Now().Add(d),
+		timer: time.AfterFunc(d, func() { ch.unpause(c, key) }),
+	}
+
+	return nil
+}
+
+// extendPause moves the end of an active pause to the given duration from now, if that is later.
+// It returns false if the container is not paused by Chaos or its pause is ending right now.
+func (ch *Chaos) extendPause(key string, d time.Duration) bool {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	pause, ok := ch.pauses[key]
+	if !ok {
+		return false
+	}
+
+	until := time.Now().Add(d)
+	if !until.After(pause.until) {
+		return true
+	}
+
+	if !pause.timer.Stop() {
+		return false
+	}
+
+	pause.until = until
+	pause.timer.Reset(d)
+
+	return true
+}
+
+func (ch *Chaos) unpause(c *Container, key string) {
+	ch.mu.Lock()
+	delete(ch.pauses, key)
+	ch.mu.Unlock()
+
+	err := ch.revert(context.WithoutCancel(ch.ctx), key)
+	if err != nil {
+		ch.logger.Error("could not unpause container",
+			operationAttr("chaos pause"), containerAttrs(c.Name, c.containerID), errorAttr(err))
+	}
+}
+
+// Latency delays the containers outgoing network traffic.
+func (ch *Chaos) Latency(c *Container, delay, jitter time.Duration) error {
+	return ch.LatencyCtx(ch.ctx, c, delay, jitter)
+}
+
+// LatencyCtx is like Latency using the given context.
+func (ch *Chaos) LatencyCtx(ctx context.Context, c *Container, delay, jitter time.Duration) error {
+	return ch.NetemCtx(ctx, c, NetemOptions{Delay: delay, Jitter: jitter})
+}
+
+// Loss drops the given percentage of the containers outgoing network packets.
+func (ch *Chaos) Loss(c *Container, percent float64) error {
+	return ch.LossCtx(ch.ctx, c, percent)
+}
+
+// LossCtx is like Loss using the given context.
+func (ch *Chaos) LossCtx(ctx context.Context, c *Container, percent float64) error {
+	return ch.NetemCtx(ctx, c, NetemOptions{Loss: percent})
+}
+
+// Bandwidth limits the containers outgoing network traffic to the given rate, for example "1mbit".
+func (ch *Chaos) Bandwidth(c *Container, rate string) error {
+	return ch.BandwidthCtx(ch.ctx, c, rate)
+}
+
+// BandwidthCtx is like Bandwidth using the given context.
+func (ch *Chaos) BandwidthCtx(ctx context.Context, c *Container, rate string) error {
+	return ch.NetemCtx(ctx, c, NetemOptions{Rate: rate})
+}
+
+// Netem applies a tc netem rule on a network interface of the container.
+// The rule is applied by a privileged helper container sharing the containers network namespace,
+// it replaces any rule applied before on the same interface.
+func (ch *Chaos) Netem(c *Container, opts NetemOptions) error {
+	return ch.NetemCtx(ch.ctx, c, opts)
+}
+
+// NetemCtx is like Netem using the given context.
+func (ch *Chaos) NetemCtx(ctx context.Context, c *Container, opts NetemOptions) error {
+	if opts.Interface == "" {
+		opts.Interface = defaultNetemInterface
+	}
+
+	args := append([]string{"qdisc", "replace", "dev", opts.Interface, "root", "netem"}, opts.args()...)
+
+	err := ch.runTc(ctx, c, args...)
+	if err != nil {
+		return err
+	}
+
+	key := fmt.Sprintf("netem:%s:%s", c.containerID, opts.Interface)
+	ch.addEffect(key, func(ctx context.Context) error {
+		return ch.runTc(ctx, c, "qdisc", "del", "dev", opts.Interface, "root")
+	})
+
+	return nil
+}
+
+// Restore reverts all effects that are still active, the most recent first.
+func (ch *Chaos) Restore(ctx context.Context) error {
+	ch.mu.Lock()
+	effects := ch.effects
+	ch.effects = nil
+
+	for _, pause := range ch.pauses {
+		pause.timer.Stop()
+	}
+
+	ch.pauses = nil
+	ch.mu.Unlock()
+
+	var errs []error
+
+	for i := len(effects) - 1; i >= 0; i-- {
+		err := effects[i].revert(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error reverting '%s': %w", effects[i].key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (o NetemOptions) args() []string {
+	var args []string
+
+	if o.Delay > 0 {
+		args = append(args, "delay", formatTcTime(o.Delay))
+		if o.Jitter > 0 {
+			args = append(args, formatTcTime(o.Jitter))
+		}
+	}
+
+	if o.Loss > 0 {
+		args = append(args, "loss", fmt.Sprintf("%g%%", o.Loss))
+	}
+
+	if o.Rate != "" {
+		args = append(args, "rate", o.Rate)
+	}
+
+	return args
+}
+
+func formatTcTime(d time.Duration) string {
+	return fmt.Sprintf("%dus", d.Microseconds())
+}
+
+func networkEffectKey(c *Container, n *Network) string {
+	return fmt.Sprintf("network:%s:%s", c.containerID, n.NetworkID)
+}
+
+// addEffect remembers how to revert an effect. If an effect with the same key is active already,
+// like a netem rule replaced by another one, its revert is kept, since it restores the original state.
+func (ch *Chaos) addEffect(key string, revert func(ctx context.Context) error) {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+
+	for _, effect := range ch.effects {
+		if effect.key == key {
+			return
+		}
+	}
+
+	ch.effects = append(ch.effects, chaosEffect{key: key, revert: revert})
+}
+
+// revert reverts and forgets the effect with the given key, unknown keys are ignored.
+func (ch *Chaos) revert(ctx context.Context, key string) error {
+	ch.mu.Lock()
+
+	var effect *chaosEffect
+
+	for i := range ch.effects {
+		if ch.effects[i].key == key {
+			e := ch.effects[i]
+			effect = &e
+			ch.effects = append(ch.effects[:i], ch.effects[i+1:]...)
+
+			break
+		}
+	}
+
+	ch.mu.Unlock()
+
+	if effect == nil {
+		return nil
+	}
+
+	return effect.revert(ctx)
+}
+
+func (ch *Chaos) endpointSettings(
+	ctx context.Context,
+	c *Container,
+	n *Network,
+) (*dockerNetwork.EndpointSettings, error) {
+	inspectResult, err := ch.dockerClient.ContainerInspect(ctx, c.containerID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInspectingContainer, err)
+	}
+
+	endpoint := &dockerNetwork.EndpointSettings{NetworkID: n.NetworkID}
+
+	if inspectResult.NetworkSettings != nil {
+		if current, ok := inspectResult.NetworkSettings.Networks[n.NetworkName]; ok {
+			endpoint.Aliases = current.Aliases
+			endpoint.IPAMConfig = current.IPAMConfig
+			endpoint.Links = current.Links
+		}
+	}
+
+	return endpoint, nil
+}
+
+func (ch *Chaos) runTc(ctx context.Context, c *Container, args ...string) error {
+	helperID, err := ch.createTcHelper(ctx, c, args...)
+	if err != nil {
+		return err
+	}
+
+	defer removeContainer(context.Background(), helperID, ch.dockerClient)
+
+	waitCh, errCh := ch.dockerClient.ContainerWait(ctx, helperID, container.WaitConditionNextExit)
+
+	err = ch.dockerClient.ContainerStart(ctx, helperID, types.ContainerStartOptions{})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case res := <-waitCh:
+		if res.StatusCode == 0 {
+			return nil
+		}
+
+		log, _ := getContainerLog(ctx, ch.dockerClient, &Container{containerID: helperID})
+
+		return fmt.Errorf("%w 'tc %s': %s", ErrNetem, strings.Join(args, " "), log)
+	}
+}
+
+func (ch *Chaos) createTcHelper(ctx context.Context, c *Container, args ...string) (string, error) {
+	config := &container.Config{
+		Image:      ch.NetemImage,
+		Entrypoint: []string{"tc"},
+		Cmd:        args,
+		Labels:     ch.labels,
+	}
+	hostConfig := &container.HostConfig{
+		NetworkMode: container.NetworkMode("container:" + c.containerID),
+		CapAdd:      []string{"NET_ADMIN"},
+		Privileged:  true,
+	}
+
+	resp, err := ch.dockerClient.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
+	if client.IsErrNotFound(err) {
+		err = pullImage(ctx, ch.dockerClient, ch.NetemImage)
+		if err != nil {
+			return "", err
+		}
+
+		resp, err = ch.dockerClient.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return resp.ID, nil
+}
+
+func pullImage(ctx context.Context, dockerClient *client.Client, image string) error {
+	reader, err := dockerClient.ImagePull(ctx, image, types.ImagePullOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	_, err = io.Copy(io.Discard, reader)
+
+	return err
+}
@@ -4,63 +4,120 @@ import (
 	"context"
 	"fmt"
 	"github.com/docker/docker/api/types/container"
+	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
 )
 
 func newCleaner(ctx context.Context, dt *Session) cleaner {
-	return cleaner{dockerClient: dt.dockerClient, ctx: ctx, containerStopTimeout: cleanerTimeout}
+	return newSessionCleaner(ctx, dt.dockerClient, dt.mainLabel, dt.logger)
+}
+
+func newSessionCleaner(ctx context.Context, dc *client.Client, label string, logger *slog.Logger) cleaner {
+	return cleaner{dockerClient: dc, ctx: ctx, label: label, containerStopTimeout: stopTimeout(ctx), logger: logger}
 }
 
 type cleaner struct {
 	ctx                  context.Context
 	dockerClient         *client.Client
+	label                string
 	containerStopTimeout time.Duration
+	// force removes reusable containers and networks too, see ContainerBuilder.Reuse.
+	force  bool
+	logger *slog.Logger
+}
+
+// filterArgs selects the components labelled with the cleaners main label.
+func (c cleaner) filterArgs() filters.Args {
+	return labelFilterArgs(c.label)
 }
 
 func (c cleaner) cleanupTestNetwork() {
-	removeNetworks(c.ctx, getBasicFilterArgs(), c.dockerClient)
+	removeNetworks(c.ctx, c.filterArgs(), c.dockerClient, !c.force, c.logger)
+}
+
+func (c cleaner) removeSessionNetworks(sessionID string) {
+	removeNetworks(c.ctx, filterSessionID(c.filterArgs(), sessionID), c.dockerClient, !c.force, c.logger)
 }
 
 func (c cleaner) removeDockerTestContainers(sessionID string) {
-	args := filterSessionID(getBasicFilterArgs(), sessionID)
+	args := filterSessionID(c.filterArgs(), sessionID)
+
+	removeContainers(c.ctx, args, c.dockerClient, !c.force, c.logger)
+}
+
+func (c cleaner) removeSessionVolumes(sessionID string) {
+	removeVolumes(c.ctx, filterSessionID(c.filterArgs(), sessionID), c.dockerClient, c.logger)
+}
 
-	removeContainers(c.ctx, args, c.dockerClient)
+func (c cleaner) removeSessionImages(sessionID string) {
+	removeImages(c.ctx, filterSessionID(c.filterArgs(), sessionID), c.dockerClient, c.logger)
 }
 
 func (c cleaner) stopSessionContainers(sessionID string) {
-	filterArgs := getBasicFilterArgs()
+	filterArgs := c.filterArgs()
 	filterArgs = filterSessionID(filterArgs, sessionID)
 	filterArgs = filterContainerRunning(filterArgs)
 
-	stopContainers(c.ctx, filterArgs, c.dockerClient, c.containerStopTimeout)
+	stopContainers(c.ctx, filterArgs, c.dockerClient, c.containerStopTimeout, !c.force, c.logger)
 }
 
-func newRemainsCleaner(ctx context.Context, dc *client.Client) remainsCleaner {
-	return remainsCleaner{dockerClient: dc, ctx: ctx, containerStopTimeout: cleanerTimeout}
+func newRemainsCleaner(ctx context.Context, dc *client.Client, label string, logger *slog.Logger) remainsCleaner {
+	return remainsCleaner{dockerClient: dc, ctx: ctx, label: label, containerStopTimeout: stopTimeout(ctx), logger: logger}
+}
+
+// stopTimeout returns the time containers are given to stop, before they are killed.
+// It ends before the contexts deadline, so there is time left to remove the containers.
+func stopTimeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return cleanerTimeout
+	}
+
+	if remaining := time.Until(deadline) / 2; remaining < cleanerTimeout {
+		return remaining
+	}
+
+	return cleanerTimeout
 }
 
 type remainsCleaner struct {
 	ctx                  context.Context
 	dockerClient         *client.Client
+	label                string
 	containerStopTimeout time.Duration
+	logger               *slog.Logger
+}
+
+// filterArgs selects the components labelled with the cleaners main label, of all sessions.
+func (c remainsCleaner) filterArgs() filters.Args {
+	return labelFilterArgs(c.label)
 }
 
 func (c remainsCleaner) cleanupTestNetwork() {
-	removeNetworks(c.ctx, getBasicFilterArgs(), c.dockerClient)
+	removeNetworks(c.ctx, c.filterArgs(), c.dockerClient, true, c.logger)
 }
 
 func (c remainsCleaner) removeDockerTestContainers() {
-	removeContainers(c.ctx, getBasicFilterArgs(), c.dockerClient)
+	removeContainers(c.ctx, c.filterArgs(), c.dockerClient, true, c.logger)
+}
+
+func (c remainsCleaner) removeVolumes() {
+	removeVolumes(c.ctx, c.filterArgs(), c.dockerClient, c.logger)
+}
+
+func (c remainsCleaner) removeImages() {
+	removeImages(c.ctx, c.filterArgs(), c.dockerClient, c.logger)
 }
 
 func (c remainsCleaner) stopContainers() {
-	stopContainers(c.ctx, getBasicFilterArgs(), c.dockerClient, c.containerStopTimeout)
+	stopContainers(c.ctx, c.filterArgs(), c.dockerClient, c.containerStopTimeout, true, c.logger)
 }
 
 func filterSessionID(args filters.Args, sessionID string) filters.Args {
@@ -75,17 +132,72 @@ func filterContainerRunning(args filters.Args) filters.Args {
 	return args
 }
 
-func removeNetworks(ctx context.Context, filterArgs filters.Args, dc *client.Client) {
+// removeNetworks removes the networks matching the filter, reusable networks are left out if they are to be kept.
+func removeNetworks(
+	ctx context.Context,
+	filterArgs filters.Args,
+	dc *client.Client,
+	keepReusable bool,
+	logger *slog.Logger,
+) {
 	res, err := dc.NetworkList(ctx, types.NetworkListOptions{Filters: filterArgs})
-	panicOnError(err)
+	if err != nil {
+		logger.Error("could not list networks", operationAttr("remove networks"), errorAttr(err))
+
+		return
+	}
 
 	for _, networkResource := range res {
-		removeNetwork(ctx, networkResource.ID, dc)
+		if _, reusable := networkResource.Labels[reuseLabel]; reusable && keepReusable {
+			continue
+		}
+
+		removeNetwork(ctx, networkResource.ID, dc, logger)
 	}
 }
 
-func removeContainers(ctx context.Context, filterArgs filters.Args, dc *client.Client) {
-	exitedContainers, err := dc.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: filterArgs})
+func removeVolumes(ctx context.Context, filterArgs filters.Args, dc *client.Client, logger *slog.Logger) {
+	res, err := dc.VolumeList(ctx, volume.ListOptions{Filters: filterArgs})
+	if err != nil {
+		logger.Error("could not list volumes", operationAttr("remove volumes"), errorAttr(err))
+
+		return
+	}
+
+	for _, v := range res.Volumes {
+		err := dc.VolumeRemove(ctx, v.Name, true)
+		if err != nil {
+			logger.Error("could not remove volume",
+				operationAttr("remove volumes"), slog.String("volume", v.Name), errorAttr(err))
+		}
+	}
+}
+
+func removeImages(ctx context.Context, filterArgs filters.Args, dc *client.Client, logger *slog.Logger) {
+	images, err := dc.ImageList(ctx, types.ImageListOptions{Filters: filterArgs})
+	if err != nil {
+		logger.Error("could not list images", operationAttr("remove images"), errorAttr(err))
+
+		return
+	}
+
+	for _, image := range images {
+		_, err := dc.ImageRemove(ctx, image.ID, types.ImageRemoveOptions{Force: true, PruneChildren: true})
+		if err != nil {
+			logger.Error("could not remove image",
+				operationAttr("remove images"), slog.String("image", image.ID), errorAttr(err))
+		}
+	}
+}
+
+func removeContainers(
+	ctx context.Context,
+	filterArgs filters.Args,
+	dc *client.Client,
+	keepReusable bool,
+	logger *slog.Logger,
+) {
+	exitedContainers, err := listContainers(ctx, filterArgs, dc, keepReusable)
 	if err == nil {
 		wg := &sync.WaitGroup{}
 		wg.Add(len(exitedContainers))
@@ -99,34 +211,68 @@ func removeContainers(ctx context.Context, filterArgs filters.Args, dc *client.C
 
 		wg.Wait()
 	} else {
-		fmt.Printf("error finding dockertest containers: %v\n", err)
+		logger.Error("could not list containers", operationAttr("remove containers"), errorAttr(err))
 	}
 }
 
-func stopContainers(ctx context.Context, filterArgs filters.Args, dc *client.Client, timeout time.Duration) {
-	containers, err := dc.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: filterArgs})
+func stopContainers(
+	ctx context.Context,
+	filterArgs filters.Args,
+	dc *client.Client,
+	timeout time.Duration,
+	keepReusable bool,
+	logger *slog.Logger,
+) {
+	containers, err := listContainers(ctx, filterArgs, dc, keepReusable)
 	if err == nil {
 		wg := &sync.WaitGroup{}
 		wg.Add(len(containers))
 
 		for _, testContainer := range containers {
 			go func(id string) {
-				go shutDownContainer(ctx, id, dc, int(timeout))
+				shutDownContainer(ctx, id, dc, timeout, logger)
 				wg.Done()
 			}(testContainer.ID)
 		}
 
 		wg.Wait()
 	} else {
-		fmt.Printf("error finding session containers: %v\n", err)
+		logger.Error("could not list containers", operationAttr("stop containers"), errorAttr(err))
 	}
 }
 
-func shutDownContainer(ctx context.Context, containerID string, dc *client.Client, timeout int) {
+// listContainers lists the containers matching the filter, reusable containers are left out if they are to be kept.
+func listContainers(ctx context.Context, filterArgs filters.Args, dc *client.Client, keepReusable bool) ([]types.Container, error) {
+	containers, err := dc.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: filterArgs})
+	if err != nil || !keepReusable {
+		return containers, err
+	}
+
+	kept := containers[:0]
+
+	for _, c := range containers {
+		if _, reusable := c.Labels[reuseLabel]; !reusable {
+			kept = append(kept, c)
+		}
+	}
+
+	return kept, nil
+}
+
+func shutDownContainer(
+	ctx context.Context,
+	containerID string,
+	dc *client.Client,
+	timeout time.Duration,
+	logger *slog.Logger,
+) {
+	// the docker API expects the stop timeout in whole seconds.
+	seconds := int(timeout.Seconds())
+
 	_ = dc.ContainerStop(ctx, containerID, container.StopOptions{
-		Timeout: &timeout,
+		Timeout: &seconds,
 	})
-	waitForContainer(ctx, containerHasFadeAway, dc, containerID)
+	waitForContainer(ctx, containerHasFadeAway, dc, containerID, logger)
 }
 
 func removeContainer(ctx context.Context, containerID string, dc *client.Client) {
@@ -136,9 +282,10 @@ func removeContainer(ctx context.Context, containerID string, dc *client.Client)
 	)
 }
 
-func removeNetwork(ctx context.Context, networkID string, dc *client.Client) {
+func removeNetwork(ctx context.Context, networkID string, dc *client.Client, logger *slog.Logger) {
 	err := dc.NetworkRemove(ctx, networkID)
 	if err != nil {
-		fmt.Printf("could not remove Network: %v\n", err)
+		logger.Error("could not remove network",
+			operationAttr("remove network"), slog.String("network", networkID), errorAttr(err))
 	}
 }
@@ -2,6 +2,7 @@ package dockertest
 
 import (
 	"context"
+	"log/slog"
 
 	"github.com/docker/docker/client"
 )
@@ -11,6 +12,7 @@ type clientEnabled struct {
 	cancelCtx    context.CancelFunc
 	ctx          context.Context
 	dockerClient *client.Client
+	logger       *slog.Logger
 }
 
 func (c clientEnabled) Cancel() {
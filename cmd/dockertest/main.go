@@ -0,0 +1,89 @@
+// Command dockertest runs test environments described by a spec file
+// and inspects and cleans up the sessions dockertest left on the docker host.
+//
+//	dockertest run [--artifacts dir] [--var KEY=VALUE]... spec.yaml
+//	dockertest sessions ls
+//	dockertest sessions rm <session-id>...
+//	dockertest prune --older-than 2h
+//	dockertest prune --reusable
+//	dockertest logs <session-id>
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Oppodelldog/dockertest"
+)
+
+// errUsage is returned for invalid command lines, the usage is printed to stderr.
+var errUsage = errors.New("invalid usage")
+
+const usage = `usage: dockertest <command> [arguments]
+
+commands:
+  run [--artifacts dir] [--var KEY=VALUE]... <spec>
+                                 run the test container of the spec, exits with its exit code
+  sessions ls                    list sessions with their containers, networks and volumes
+  sessions rm <session-id>...    stop and remove all components of the given sessions
+  prune --older-than <duration>  remove all sessions older than the given duration, like 2h
+  prune --reusable               remove all reusable containers and networks
+  logs <session-id>              write the logs of all containers of the session
+`
+
+func main() {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	// after the first signal cancelled the session, a second one terminates immediately.
+	context.AfterFunc(ctx, cancel)
+
+	err := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+
+	cancel()
+
+	var exitErr *dockertest.ExitError
+	if errors.As(err, &exitErr) {
+		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitErr.ExitCode)
+	}
+
+	if errors.Is(err, errUsage) {
+		_, _ = fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+		os.Exit(2) //nolint:gomnd
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing command", errUsage)
+	}
+
+	logger := slog.New(slog.NewTextHandler(stderr, nil))
+
+	switch args[0] {
+	case "run":
+		return runCommand(ctx, args[1:], stdout, logger)
+	case "sessions":
+		return sessionsCommand(ctx, args[1:], stdout, logger)
+	case "prune":
+		return pruneCommand(ctx, args[1:], stdout, logger)
+	case "logs":
+		return logsCommand(ctx, args[1:], stdout, logger)
+	case "help", "-h", "--help":
+		_, err := io.WriteString(stdout, usage)
+
+		return err
+	default:
+		return fmt.Errorf("%w: unknown command '%s'", errUsage, args[0])
+	}
+}
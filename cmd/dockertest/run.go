@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/Oppodelldog/dockertest"
+)
+
+const finishTimeout = time.Minute
+
+// variables collects repeated --var KEY=VALUE flags.
+type variables map[string]string
+
+func (v variables) String() string {
+	return fmt.Sprint(map[string]string(v))
+}
+
+func (v variables) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return fmt.Errorf("%w: variable '%s' is not KEY=VALUE", errUsage, s)
+	}
+
+	v[key] = value
+
+	return nil
+}
+
+// runCommand brings up the environment of the spec and runs its test container.
+// A failing test container is returned as *dockertest.ExitError, its exit code becomes the exit code of the command.
+func runCommand(ctx context.Context, args []string, stdout io.Writer, logger *slog.Logger) error {
+	vars := variables{}
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	artifactsDir := flags.String("artifacts", "dockertest-artifacts", "directory the artifacts are collected into")
+	flags.Var(vars, "var", "KEY=VALUE variable for the spec interpolation, may be repeated")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("%w: run requires exactly one spec file", errUsage)
+	}
+
+	spec, err := dockertest.LoadSpec(flags.Arg(0), vars)
+	if err != nil {
+		return err
+	}
+
+	if spec.Test == "" {
+		return fmt.Errorf("%w: spec '%s' does not name a test container", dockertest.ErrInvalidSpec, flags.Arg(0))
+	}
+
+	session, err := dockertest.NewSession(dockertest.WithLogger(logger))
+	if err != nil {
+		return err
+	}
+
+	stopCancelling := context.AfterFunc(ctx, session.Cancel)
+	defer stopCancelling()
+
+	logger.Info("starting environment", slog.String("session", session.ID), slog.String("spec", flags.Arg(0)))
+
+	_, result, runErr := session.RunSpec(ctx, spec, stdout)
+
+	finishCtx, cancel := context.WithTimeout(context.Background(), finishTimeout)
+	defer cancel()
+
+	manifest, err := session.CollectArtifacts(finishCtx, *artifactsDir)
+	if err != nil {
+		logger.Error("could not collect artifacts", slog.Any("error", err))
+	} else {
+		logger.Info("collected artifacts", slog.String("dir", manifest.Dir))
+	}
+
+	session.CleanupCtx(finishCtx)
+
+	if runErr == nil {
+		logger.Info("tests passed", slog.Duration("duration", result.Duration))
+	}
+
+	return runErr
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Oppodelldog/dockertest"
+)
+
+func sessionsCommand(ctx context.Context, args []string, stdout io.Writer, logger *slog.Logger) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing sessions subcommand", errUsage)
+	}
+
+	sessions, err := dockertest.NewSessions(logger)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "ls":
+		return listSessions(ctx, sessions, stdout)
+	case "rm":
+		if len(args) < 2 { //nolint:gomnd
+			return fmt.Errorf("%w: missing session id", errUsage)
+		}
+
+		for _, id := range args[1:] {
+			err := sessions.Remove(ctx, id)
+			if err != nil {
+				return err
+			}
+
+			_, _ = fmt.Fprintf(stdout, "removed session %s\n", id)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown sessions subcommand '%s'", errUsage, args[0])
+	}
+}
+
+func pruneCommand(ctx context.Context, args []string, stdout io.Writer, logger *slog.Logger) error {
+	flags := flag.NewFlagSet("prune", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	olderThan := flags.Duration("older-than", 0, "remove sessions older than the given duration")
+	reusable := flags.Bool("reusable", false, "remove reusable containers and networks")
+
+	err := flags.Parse(args)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errUsage, err)
+	}
+
+	if *olderThan <= 0 && !*reusable {
+		return fmt.Errorf("%w: prune requires --older-than or --reusable", errUsage)
+	}
+
+	sessions, err := dockertest.NewSessions(logger)
+	if err != nil {
+		return err
+	}
+
+	if *reusable {
+		containers, err := sessions.PruneReusable(ctx)
+		for _, c := range containers {
+			_, _ = fmt.Fprintf(stdout, "removed reusable container %s\n", c.Name)
+		}
+
+		if err != nil || *olderThan <= 0 {
+			return err
+		}
+	}
+
+	pruned, err := sessions.Prune(ctx, *olderThan)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range pruned {
+		_, _ = fmt.Fprintf(stdout, "removed session %s\n", info.ID)
+	}
+
+	return nil
+}
+
+func logsCommand(ctx context.Context, args []string, stdout io.Writer, logger *slog.Logger) error {
+	if len(args) != 1 {
+		return fmt.Errorf("%w: logs requires exactly one session id", errUsage)
+	}
+
+	sessions, err := dockertest.NewSessions(logger)
+	if err != nil {
+		return err
+	}
+
+	return sessions.WriteLogs(ctx, stdout, args[0])
+}
+
+func listSessions(ctx context.Context, sessions *dockertest.Sessions, stdout io.Writer) error {
+	infos, err := sessions.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0) //nolint:gomnd
+	_, _ = fmt.Fprintln(w, "SESSION\tLABEL\tAGE\tCONTAINERS\tNETWORKS\tVOLUMES")
+
+	for _, info := range infos {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%v/%v running\t%s\t%s\n",
+			info.ID,
+			info.Label,
+			age(info.Created),
+			info.Running(),
+			len(info.Containers),
+			strings.Join(info.Networks, ","),
+			strings.Join(info.Volumes, ","),
+		)
+
+		for _, c := range info.Containers {
+			status := c.Status
+			if c.Reusable {
+				status += " (reusable)"
+			}
+
+			_, _ = fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t\t\n", c.Name, c.Image, c.State, status)
+		}
+	}
+
+	return w.Flush()
+}
+
+func age(created time.Time) string {
+	if created.IsZero() {
+		return "unknown"
+	}
+
+	return time.Since(created).Round(time.Second).String()
+}
@@ -0,0 +1,571 @@
+package dockertest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidCompose is returned if a compose file uses a syntax that cannot be mapped onto a Spec.
+var ErrInvalidCompose = errors.New("invalid compose file")
+
+var errUnterminatedQuote = errors.New("unterminated quote or escape")
+
+const composeDefaultNetwork = "default"
+
+// LoadCompose creates and starts the services of a docker-compose file.
+// The given override files are merged into the compose file in order, like "docker compose -f".
+// All resources are created with the session labels and session-suffixed names, so they are
+// covered by Cleanup and the Dump functions. The containers are returned by their service name.
+//
+// Only the subset of compose v3 is supported that maps onto the ContainerBuilder and NetworkBuilder:
+// services with image, build, command, environment, env_file, ports, volumes, networks, depends_on and healthcheck.
+// Variables of the environment and the .env file are interpolated following the compose rules.
+func (dt *Session) LoadCompose(path string, overrides ...string) (map[string]*Container, error) {
+	return dt.LoadComposeCtx(dt.ctx, path, overrides...)
+}
+
+// LoadComposeCtx is like LoadCompose using the given context.
+func (dt *Session) LoadComposeCtx(ctx context.Context, path string, overrides ...string) (map[string]*Container, error) {
+	spec, err := loadCompose(path, overrides...)
+	if err != nil {
+		return map[string]*Container{}, err
+	}
+
+	return dt.Apply(ctx, spec)
+}
+
+type composeFile struct {
+	Services map[string]composeService `yaml:"services"`
+	Networks map[string]yaml.Node      `yaml:"networks"`
+	Volumes  map[string]composeVolume  `yaml:"volumes"`
+}
+
+type composeService struct {
+	Image       string              `yaml:"image"`
+	Build       *composeBuild       `yaml:"build"`
+	Command     composeCommand      `yaml:"command"`
+	Environment composeEnvironment  `yaml:"environment"`
+	EnvFile     composeList         `yaml:"env_file"`    //nolint:tagliatelle
+	WorkingDir  string              `yaml:"working_dir"` //nolint:tagliatelle
+	Ports       []string            `yaml:"ports"`
+	Expose      []string            `yaml:"expose"`
+	Volumes     []string            `yaml:"volumes"`
+	Networks    composeNetworks     `yaml:"networks"`
+	DependsOn   composeDependsOn    `yaml:"depends_on"` //nolint:tagliatelle
+	Healthcheck *composeHealthcheck `yaml:"healthcheck"`
+}
+
+type composeVolume struct {
+	Driver string `yaml:"driver"`
+}
+
+type composeBuild struct {
+	Context    string             `yaml:"context"`
+	Dockerfile string             `yaml:"dockerfile"`
+	Args       composeEnvironment `yaml:"args"`
+	Target     string             `yaml:"target"`
+}
+
+type composeHealthcheck struct {
+	Test        composeCommand `yaml:"test"`
+	Interval    time.Duration  `yaml:"interval"`
+	Timeout     time.Duration  `yaml:"timeout"`
+	StartPeriod time.Duration  `yaml:"start_period"` //nolint:tagliatelle
+	Retries     int            `yaml:"retries"`
+	Disable     bool           `yaml:"disable"`
+}
+
+// composeCommand is either a list or a string which is split into fields.
+type composeCommand []string
+
+// composeList is either a list or a single string.
+type composeList []string
+
+// composeEnvironment is either a map or a list of KEY=VALUE entries.
+type composeEnvironment map[string]string
+
+// composeNetworks is either a list of network names or a map of network names to their settings.
+type composeNetworks map[string][]string
+
+// composeDependsOn is either a list of service names or a map of service names to their condition.
+type composeDependsOn map[string]string
+
+func (b *composeBuild) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		b.Context = n.Value
+
+		return nil
+	}
+
+	type plain composeBuild
+
+	return n.Decode((*plain)(b))
+}
+
+func (c *composeCommand) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		words, err := splitShellWords(n.Value)
+		if err != nil {
+			return fmt.Errorf("%w: command '%s': %w", ErrInvalidCompose, n.Value, err)
+		}
+
+		*c = words
+
+		return nil
+	}
+
+	return n.Decode((*[]string)(c))
+}
+
+// splitShellWords splits a command string like a POSIX shell, as compose does for the string form of command
+// and entrypoint: single quotes keep their content literally, double quotes and backslashes escape as in sh.
+func splitShellWords(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		escaped bool
+		quote   byte
+	)
+
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+
+		switch {
+		case escaped:
+			word.WriteByte(ch)
+			escaped = false
+		case quote == '\'':
+			if ch == '\'' {
+				quote = 0
+			} else {
+				word.WriteByte(ch)
+			}
+		case quote == '"':
+			switch {
+			case ch == '"':
+				quote = 0
+			case ch == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0:
+				i++
+				word.WriteByte(s[i])
+			default:
+				word.WriteByte(ch)
+			}
+		case ch == '\\':
+			escaped = true
+			inWord = true
+		case ch == '\'' || ch == '"':
+			quote = ch
+			inWord = true
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(ch)
+			inWord = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, errUnterminatedQuote
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+func (l *composeList) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode {
+		*l = []string{n.Value}
+
+		return nil
+	}
+
+	return n.Decode((*[]string)(l))
+}
+
+func (e *composeEnvironment) UnmarshalYAML(n *yaml.Node) error {
+	*e = composeEnvironment{}
+
+	if n.Kind == yaml.MappingNode {
+		return n.Decode((*map[string]string)(e))
+	}
+
+	var entries []string
+
+	err := n.Decode(&entries)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok {
+			v, ok = os.LookupEnv(k)
+		}
+
+		if ok {
+			(*e)[k] = v
+		}
+	}
+
+	return nil
+}
+
+func (c *composeNetworks) UnmarshalYAML(n *yaml.Node) error {
+	*c = composeNetworks{}
+
+	if n.Kind == yaml.SequenceNode {
+		var names []string
+
+		err := n.Decode(&names)
+		for _, name := range names {
+			(*c)[name] = nil
+		}
+
+		return err
+	}
+
+	var networks map[string]*struct {
+		Aliases []string `yaml:"aliases"`
+	}
+
+	err := n.Decode(&networks)
+	for name, settings := range networks {
+		(*c)[name] = nil
+		if settings != nil {
+			(*c)[name] = settings.Aliases
+		}
+	}
+
+	return err
+}
+
+func (d *composeDependsOn) UnmarshalYAML(n *yaml.Node) error {
+	*d = composeDependsOn{}
+
+	if n.Kind == yaml.SequenceNode {
+		var names []string
+
+		err := n.Decode(&names)
+		for _, name := range names {
+			(*d)[name] = "service_started"
+		}
+
+		return err
+	}
+
+	var dependencies map[string]struct {
+		Condition string `yaml:"condition"`
+	}
+
+	err := n.Decode(&dependencies)
+	for name, dependency := range dependencies {
+		(*d)[name] = dependency.Condition
+	}
+
+	return err
+}
+
+// loadCompose reads, interpolates and merges the compose files and converts them into a Spec.
+func loadCompose(path string, overrides ...string) (*Spec, error) {
+	baseDir, err := filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	dotEnv, err := readEnvFile(filepath.Join(baseDir, ".env"))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var merged map[string]interface{}
+
+	for _, p := range append([]string{path}, overrides...) {
+		doc, err := readComposeDocument(p, dotEnv)
+		if err != nil {
+			return nil, err
+		}
+
+		merged = mergeComposeDocuments(merged, doc)
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, err
+	}
+
+	var compose composeFile
+
+	err = yaml.Unmarshal(data, &compose)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCompose, err)
+	}
+
+	spec, err := compose.spec(baseDir)
+	if err != nil {
+		return nil, err
+	}
+
+	return spec, spec.validate()
+}
+
+func readComposeDocument(path string, dotEnv map[string]string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	expanded, err := interpolateCompose(string(data), func(name string) (string, bool) {
+		if v, ok := os.LookupEnv(name); ok {
+			return v, true
+		}
+
+		v, ok := dotEnv[name]
+
+		return v, ok
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w '%s': %w", ErrInvalidCompose, path, err)
+	}
+
+	var doc map[string]interface{}
+
+	err = yaml.Unmarshal([]byte(expanded), &doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w '%s': %w", ErrInvalidCompose, path, err)
+	}
+
+	return doc, nil
+}
+
+// mergeComposeDocuments merges the override into the base document, maps are merged recursively
+// while all other values of the override replace the ones of the base.
+func mergeComposeDocuments(base, override map[string]interface{}) map[string]interface{} {
+	if base == nil {
+		return override
+	}
+
+	for k, v := range override {
+		baseMap, baseIsMap := base[k].(map[string]interface{})
+		overrideMap, overrideIsMap := v.(map[string]interface{})
+
+		if baseIsMap && overrideIsMap {
+			base[k] = mergeComposeDocuments(baseMap, overrideMap)
+
+			continue
+		}
+
+		base[k] = v
+	}
+
+	return base
+}
+
+func readEnvFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	env := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		k, v, _ := strings.Cut(line, "=")
+		env[strings.TrimSpace(k)] = strings.Trim(strings.TrimSpace(v), `"'`)
+	}
+
+	return env, scanner.Err()
+}
+
+func (f composeFile) spec(baseDir string) (*Spec, error) {
+	spec := &Spec{
+		Networks:   map[string]NetworkSpec{},
+		Volumes:    map[string]VolumeSpec{},
+		Containers: map[string]ContainerSpec{},
+		baseDir:    baseDir,
+	}
+
+	for name := range f.Networks {
+		spec.Networks[name] = NetworkSpec{AutoSubnet: true}
+	}
+
+	for name, v := range f.Volumes {
+		spec.Volumes[name] = VolumeSpec{Driver: v.Driver}
+	}
+
+	for name, service := range f.Services {
+		c, err := service.containerSpec(spec, baseDir)
+		if err != nil {
+			return nil, fmt.Errorf("%w: service '%s': %w", ErrInvalidCompose, name, err)
+		}
+
+		spec.Containers[name] = c
+	}
+
+	f.applyDependencyConditions(spec)
+
+	return spec, nil
+}
+
+func (s composeService) containerSpec(spec *Spec, baseDir string) (ContainerSpec, error) {
+	c := ContainerSpec{
+		Image:      s.Image,
+		Cmd:        s.Command,
+		WorkingDir: s.WorkingDir,
+		Expose:     s.Expose,
+		Mounts:     composeMounts(s.Volumes),
+		Env:        map[string]string{},
+		Aliases:    map[string][]string{},
+	}
+
+	for _, envFile := range s.EnvFile {
+		env, err := readEnvFile(filepath.Join(baseDir, envFile))
+		if err != nil {
+			return c, err
+		}
+
+		for k, v := range env {
+			c.Env[k] = v
+		}
+	}
+
+	for k, v := range s.Environment {
+		c.Env[k] = v
+	}
+
+	for _, port := range s.Ports {
+		port, err := composePort(port)
+		if err != nil {
+			return c, err
+		}
+
+		c.Ports = append(c.Ports, port)
+	}
+
+	if s.Build != nil {
+		c.Build = &BuildSpec{
+			Context:    s.Build.Context,
+			Dockerfile: s.Build.Dockerfile,
+			Args:       s.Build.Args,
+			Target:     s.Build.Target,
+		}
+	}
+
+	if len(s.Networks) == 0 {
+		// like docker compose, services without networks are attached to a default network.
+		s.Networks = composeNetworks{composeDefaultNetwork: nil}
+		spec.Networks[composeDefaultNetwork] = NetworkSpec{AutoSubnet: true}
+	}
+
+	for _, name := range sortedKeys(s.Networks) {
+		c.Networks = append(c.Networks, name)
+		if len(s.Networks[name]) > 0 {
+			c.Aliases[name] = s.Networks[name]
+		}
+	}
+
+	c.DependsOn = sortedKeys(s.DependsOn)
+	c.Health = s.Healthcheck.healthSpec()
+
+	return c, nil
+}
+
+// applyDependencyConditions translates the conditions dependents put on a service into the services WaitSpec.
+func (f composeFile) applyDependencyConditions(spec *Spec) {
+	for _, service := range f.Services {
+		for dependency, condition := range service.DependsOn {
+			c := spec.Containers[dependency]
+			if c.Wait == nil {
+				c.Wait = &WaitSpec{}
+			}
+
+			switch condition {
+			case "service_healthy":
+				c.Wait.Healthy = true
+			case "service_completed_successfully":
+				c.Wait.Exit = true
+			}
+
+			spec.Containers[dependency] = c
+		}
+	}
+}
+
+func (h *composeHealthcheck) healthSpec() *HealthSpec {
+	if h == nil {
+		return nil
+	}
+
+	health := &HealthSpec{
+		Interval:    h.Interval,
+		Timeout:     h.Timeout,
+		StartPeriod: h.StartPeriod,
+		Retries:     h.Retries,
+		Disable:     h.Disable,
+	}
+
+	switch {
+	case len(h.Test) == 0:
+	case h.Test[0] == "NONE":
+		health.Disable = true
+	case h.Test[0] == "CMD" || h.Test[0] == "CMD-SHELL":
+		health.Test = h.Test
+	default:
+		health.ShellCmd = strings.Join(h.Test, " ")
+	}
+
+	return health
+}
+
+// composePort validates compose port mappings like "127.0.0.1:8080:80/tcp", "80" or "9000-9002:9000-9002".
+// They share the syntax of the specs ports.
+func composePort(port string) (string, error) {
+	_, err := nat.ParsePortSpec(port)
+	if err != nil {
+		return "", fmt.Errorf("invalid port '%s': %w", port, err)
+	}
+
+	return port, nil
+}
+
+// interpolateCompose replaces variables following the compose file rules: unlike in specs,
+// bare $VAR expressions are replaced too, a literal $ must be escaped as $$.
+func interpolateCompose(s string, lookup lookupFunc) (string, error) {
+	return expandVariables(s, lookup, true)
+}
+
+// composeMounts keeps the volumes of a service that bind a host path or a named volume, anonymous volumes are skipped.
+func composeMounts(volumes []string) []string {
+	var mounts []string
+
+	for _, v := range volumes {
+		if strings.Contains(v, ":") {
+			mounts = append(mounts, v)
+		}
+	}
+
+	return mounts
+}
@@ -0,0 +1,116 @@
+package dockertest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestInterpolateCompose(t *testing.T) {
+	vars := map[string]string{"TAG": "1.21", "EMPTY": ""}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{name: "bare variable", input: "image: golang:$TAG", want: "image: golang:1.21"},
+		{name: "bare variable followed by text", input: "$TAG-alpine", want: "1.21-alpine"},
+		{name: "braced variable", input: "image: golang:${TAG}", want: "image: golang:1.21"},
+		{name: "unset bare variable", input: "echo $UNSET", want: "echo "},
+		{name: "escaped dollar", input: "test: echo $$HOSTNAME", want: "test: echo $HOSTNAME"},
+		{name: "escaped braces", input: "echo $${TAG}", want: "echo ${TAG}"},
+		{name: "dollar before digit", input: "price: 5$1", want: "price: 5$1"},
+		{name: "default for empty", input: "${EMPTY:-latest}", want: "latest"},
+		{name: "dash default keeps empty", input: "${EMPTY-latest}", want: ""},
+		{name: "dash default for unset", input: "${UNSET-latest}", want: "latest"},
+		{name: "nested default", input: "${UNSET:-$TAG}", want: "1.21"},
+		{name: "required unset", input: "${UNSET:?TAG must be set}", wantErr: ErrMissingVariable},
+		{name: "required empty", input: "${EMPTY:?TAG must be set}", wantErr: ErrMissingVariable},
+		{name: "required without colon allows empty", input: "${EMPTY?TAG must be set}", want: ""},
+		{name: "required unset without colon", input: "${UNSET?TAG must be set}", wantErr: ErrMissingVariable},
+		{name: "alternative value", input: "${TAG:+debug}", want: "debug"},
+		{name: "unterminated", input: "${TAG", wantErr: ErrInvalidInterpolation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := interpolateCompose(tt.input, testLookup(vars))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("expected '%s', got '%s'", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestComposePort(t *testing.T) {
+	tests := []struct {
+		port    string
+		wantErr bool
+	}{
+		{port: "80"},
+		{port: "8080:80"},
+		{port: "8080:80/udp"},
+		{port: "127.0.0.1:8080:80"},
+		{port: "127.0.0.1::80"},
+		{port: "[::1]:8080:80"},
+		{port: "9000-9002:9000-9002"},
+		{port: "9000-9002"},
+		{port: "8000-8010:80"},
+		{port: "9000-9002:9000-9001", wantErr: true},
+		{port: "8080:80/http", wantErr: true},
+		{port: "localhost:8080:80", wantErr: true},
+		{port: "8080:", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.port, func(t *testing.T) {
+			got, err := composePort(tt.port)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if err == nil && got != tt.port {
+				t.Fatalf("expected '%s', got '%s'", tt.port, got)
+			}
+		})
+	}
+}
+
+func TestSplitShellWords(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []string
+		wantErr bool
+	}{
+		{input: "redis-server --appendonly yes", want: []string{"redis-server", "--appendonly", "yes"}},
+		{input: `sh -c "echo a b"`, want: []string{"sh", "-c", "echo a b"}},
+		{input: `sh -c 'echo "$HOME"'`, want: []string{"sh", "-c", `echo "$HOME"`}},
+		{input: `echo "say \"hi\"" \$HOME`, want: []string{"echo", `say "hi"`, "$HOME"}},
+		{input: `echo a\ b`, want: []string{"echo", "a b"}},
+		{input: `echo "a\b"`, want: []string{"echo", `a\b`}},
+		{input: `echo '' ""`, want: []string{"echo", "", ""}},
+		{input: "  spaced   out  ", want: []string{"spaced", "out"}},
+		{input: "", want: nil},
+		{input: `sh -c "echo`, wantErr: true},
+		{input: `echo \`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := splitShellWords(tt.input)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package dockertest
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -17,26 +18,63 @@ import (
 // ErrContainerStillRunning is returned from a call to ExitCode() if the container is still running.
 var ErrContainerStillRunning = errors.New("container is running, it has no exit code yet")
 
+// ErrConnectingNetwork is returned from Build if the container could not be attached to one of its networks.
+var ErrConnectingNetwork = errors.New("error connecting container to network")
+
+// ErrPrimaryNetworkNotConnected is returned from Build if the container is connected to several networks,
+// but none of them is the network set as NetworkMode.
+var ErrPrimaryNetworkNotConnected = errors.New("primary network is not among the connected networks")
+
 // ErrInspectingContainer is returned from a call to ExitCode() if the docker client returned an error on inspect.
 var ErrInspectingContainer = errors.New("error inspecting container")
 
 // Container is a access wrapper for a docker container.
 type Container struct {
-	Name         string
+	Name string
+	// NameConflict tells how a name conflict was resolved on Build, it is nil if there was none.
+	NameConflict *NameConflictResolution
 	startOptions types.ContainerStartOptions
 	containerID  string
+	dependencies []dependency
+	readiness    []Condition
+	logConsumers []LogConsumer
+	session      *Session
 	clientEnabled
 }
 
 // Start starts the container.
 func (c Container) Start() error {
-	return c.dockerClient.ContainerStart(c.ctx, c.containerID, c.startOptions)
+	return c.StartCtx(c.ctx)
+}
+
+// StartCtx starts the container using the given context.
+func (c Container) StartCtx(ctx context.Context) error {
+	return c.start(ctx)
+}
+
+// start starts the container and lets the session know about it.
+func (c *Container) start(ctx context.Context) error {
+	err := c.dockerClient.ContainerStart(ctx, c.containerID, c.startOptions)
+	if err != nil {
+		return startError(c.Name, err)
+	}
+
+	if c.session != nil {
+		c.session.containerStarted(c)
+	}
+
+	return nil
 }
 
 // ExitCode returns the exit code of the container.
 // The container must be exited and exist, otherwise an error is returned.
 func (c Container) ExitCode() (int, error) {
-	inspectResult, inspectError := c.dockerClient.ContainerInspect(c.ctx, c.containerID)
+	return c.ExitCodeCtx(c.ctx)
+}
+
+// ExitCodeCtx returns the exit code of the container using the given context.
+func (c Container) ExitCodeCtx(ctx context.Context) (int, error) {
+	inspectResult, inspectError := c.dockerClient.ContainerInspect(ctx, c.containerID)
 	if inspectError != nil {
 		return -1, fmt.Errorf("%w: %w", ErrInspectingContainer, inspectError)
 	}
@@ -48,16 +86,46 @@ func (c Container) ExitCode() (int, error) {
 	return inspectResult.State.ExitCode, nil
 }
 
+// ConnectNetwork attaches the running or created container to the given Network.
+func (c Container) ConnectNetwork(n *Network, aliases ...string) error {
+	return c.ConnectNetworkCtx(c.ctx, n, aliases...)
+}
+
+// ConnectNetworkCtx attaches the running or created container to the given Network using the given context.
+func (c Container) ConnectNetworkCtx(ctx context.Context, n *Network, aliases ...string) error {
+	return c.dockerClient.NetworkConnect(ctx, n.NetworkID, c.containerID, &dockerNetwork.EndpointSettings{
+		NetworkID: n.NetworkID,
+		Aliases:   aliases,
+	})
+}
+
+// DisconnectNetwork detaches the container from the given Network.
+func (c Container) DisconnectNetwork(n *Network) error {
+	return c.DisconnectNetworkCtx(c.ctx, n)
+}
+
+// DisconnectNetworkCtx detaches the container from the given Network using the given context.
+func (c Container) DisconnectNetworkCtx(ctx context.Context, n *Network) error {
+	return c.dockerClient.NetworkDisconnect(ctx, n.NetworkID, c.containerID, false)
+}
+
 // ContainerBuilder helps to create customized containers.
 // Note that calling functions have not affect to running or already created container.
 // only when calling the "Build" method all configuration is applied to a new container.
 type ContainerBuilder struct {
-	ContainerConfig  *container.Config
-	HostConfig       *container.HostConfig
-	NetworkingConfig *dockerNetwork.NetworkingConfig
-	ContainerName    string
-	originalName     string
-	sessionID        string
+	ContainerConfig    *container.Config
+	HostConfig         *container.HostConfig
+	NetworkingConfig   *dockerNetwork.NetworkingConfig
+	ContainerName      string
+	originalName       string
+	sessionID          string
+	session            *Session
+	dependencies       []dependency
+	readiness          []Condition
+	logConsumers       []LogConsumer
+	reuse              bool
+	nameConflictPolicy NameConflictPolicy
+	err                error
 	clientEnabled
 }
 
@@ -67,41 +135,174 @@ func (b *ContainerBuilder) NewContainerBuilder() *ContainerBuilder {
 	newBuilder.ctx = b.ctx
 	newBuilder.dockerClient = b.dockerClient
 	newBuilder.sessionID = b.sessionID
+	newBuilder.session = b.session
 	newBuilder.originalName = b.originalName
+	newBuilder.dependencies = append([]dependency{}, b.dependencies...)
+	newBuilder.readiness = append([]Condition{}, b.readiness...)
+	newBuilder.logConsumers = append([]LogConsumer{}, b.logConsumers...)
+	newBuilder.reuse = b.reuse
+	newBuilder.nameConflictPolicy = b.nameConflictPolicy
+	newBuilder.err = b.err
 
 	return newBuilder
 }
 
 // Build creates a container from the current builders state.
+// If the container is connected to more than one network, the first network
+// is used for creation and the container is attached to all others afterwards.
 func (b *ContainerBuilder) Build() (*Container, error) {
-	containerBody, err := b.dockerClient.ContainerCreate(
-		b.ctx,
-		b.ContainerConfig,
-		b.HostConfig,
-		b.NetworkingConfig,
-		nil,
-		b.ContainerName,
-	)
+	return b.BuildCtx(b.ctx)
+}
+
+// BuildCtx creates a container from the current builders state using the given context.
+// The context is used for creation only, it is not bound to the created container.
+func (b *ContainerBuilder) BuildCtx(ctx context.Context) (*Container, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	var reuseHash string
+
+	if b.reuse {
+		c, hash, err := b.reusableContainer(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if c != nil && b.session != nil {
+			// the container is running already, but its logs are followed like for started ones.
+			b.session.containerStarted(c)
+		}
+
+		if c != nil {
+			return c, nil
+		}
+
+		reuseHash = hash
+	}
+
+	networkingConfig, additionalEndpoints, err := b.splitEndpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	// the config is copied, so the builder can build further containers without the MAC address and reuse label.
+	config := *b.ContainerConfig
+
+	if reuseHash != "" {
+		config.Labels = map[string]string{}
+
+		for k, v := range b.ContainerConfig.Labels {
+			config.Labels[k] = v
+		}
+
+		config.Labels[reuseLabel] = reuseHash
+	}
+
+	if primary, ok := networkingConfig.EndpointsConfig[string(b.HostConfig.NetworkMode)]; ok && primary.MacAddress != "" {
+		// older docker daemons only respect the containers MAC address for the primary network.
+		config.MacAddress = primary.MacAddress
+	}
+
+	containerID, name, resolution, err := b.createContainer(ctx, &config, networkingConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Container{
-		Name:          b.ContainerName,
-		containerID:   containerBody.ID,
+	for networkName, endpoint := range additionalEndpoints {
+		err = b.dockerClient.NetworkConnect(ctx, networkName, containerID, endpoint)
+		if err != nil {
+			removeContainer(ctx, containerID, b.dockerClient)
+
+			return nil, fmt.Errorf("%w '%s': %w", ErrConnectingNetwork, networkName, err)
+		}
+	}
+
+	c := b.container(containerID, name)
+	c.NameConflict = resolution
+
+	return c, nil
+}
+
+// container returns the Container for the given docker container and registers it at the session.
+func (b *ContainerBuilder) container(id, name string) *Container {
+	c := &Container{
+		Name:          name,
+		containerID:   id,
+		dependencies:  b.dependencies,
+		readiness:     b.readiness,
+		logConsumers:  b.logConsumers,
+		session:       b.session,
 		clientEnabled: b.clientEnabled,
-	}, nil
+	}
+
+	if b.session != nil {
+		b.session.register(c)
+	}
+
+	return c
+}
+
+// splitEndpoints separates the endpoint of the primary network, which is passed on container creation,
+// from all further endpoints that must be connected after the container was created.
+// It fails if there are several endpoints, but none of them belongs to the primary network.
+func (b *ContainerBuilder) splitEndpoints() (
+	*dockerNetwork.NetworkingConfig,
+	map[string]*dockerNetwork.EndpointSettings,
+	error,
+) {
+	primaryNetwork := string(b.HostConfig.NetworkMode)
+	if len(b.NetworkingConfig.EndpointsConfig) <= 1 {
+		return b.NetworkingConfig, nil, nil
+	}
+
+	if _, ok := b.NetworkingConfig.EndpointsConfig[primaryNetwork]; !ok {
+		return nil, nil, fmt.Errorf("%w: '%s'", ErrPrimaryNetworkNotConnected, primaryNetwork)
+	}
+
+	networkingConfig := &dockerNetwork.NetworkingConfig{
+		EndpointsConfig: map[string]*dockerNetwork.EndpointSettings{},
+	}
+	additionalEndpoints := map[string]*dockerNetwork.EndpointSettings{}
+
+	for networkName, endpoint := range b.NetworkingConfig.EndpointsConfig {
+		if networkName == primaryNetwork {
+			networkingConfig.EndpointsConfig[networkName] = endpoint
+
+			continue
+		}
+
+		additionalEndpoints[networkName] = endpoint
+	}
+
+	return networkingConfig, additionalEndpoints, nil
 }
 
 // Connect connects the container to the given Network.
+// It may be called multiple times to attach the container to several networks,
+// the first connected network becomes the containers primary network.
 func (b *ContainerBuilder) Connect(n *Network) *ContainerBuilder {
-	b.HostConfig.NetworkMode = container.NetworkMode(n.NetworkName)
+	if b.HostConfig.NetworkMode == "" || b.HostConfig.NetworkMode.IsDefault() {
+		b.HostConfig.NetworkMode = container.NetworkMode(n.NetworkName)
+	}
+
 	b.ensureNetworkConfig(n)
 	b.NetworkingConfig.EndpointsConfig[n.NetworkName].NetworkID = n.NetworkID
 
 	return b
 }
 
+// NetworkAliases adds DNS aliases under which the container is reachable in the given Network.
+func (b *ContainerBuilder) NetworkAliases(n *Network, aliases ...string) *ContainerBuilder {
+	b.ensureNetworkConfig(n)
+	b.NetworkingConfig.EndpointsConfig[n.NetworkName].Aliases = append(
+		b.NetworkingConfig.EndpointsConfig[n.NetworkName].Aliases,
+		aliases...,
+	)
+
+	return b
+}
+
 // Mount creates a volume binding to mount a local directory into the container.
 func (b *ContainerBuilder) Mount(localPath string, containerPath string) *ContainerBuilder {
 	b.HostConfig.Binds = append(b.HostConfig.Binds, fmt.Sprintf("%s:%s", localPath, containerPath))
@@ -190,6 +391,14 @@ func (b *ContainerBuilder) HealthInterval(d time.Duration) *ContainerBuilder {
 	return b
 }
 
+// HealthStartPeriod sets the time to wait for the container to initialize before failing checks are counted.
+func (b *ContainerBuilder) HealthStartPeriod(d time.Duration) *ContainerBuilder {
+	b.ensureHealth()
+	b.ContainerConfig.Healthcheck.StartPeriod = d
+
+	return b
+}
+
 // HealthRetries sets the number of consecutive failures needed to consider a container as unhealthy.
 func (b *ContainerBuilder) HealthRetries(r int) *ContainerBuilder {
 	b.ensureHealth()
@@ -227,6 +436,7 @@ func (b *ContainerBuilder) Dns(dnsServerIP string) *ContainerBuilder {
 }
 
 // UseOriginalName removes the unique session-identifier from the container name.
+// Containers of previous test runs may still use the name then, see OnNameConflict.
 func (b *ContainerBuilder) UseOriginalName() *ContainerBuilder {
 	b.ContainerName = b.originalName
 
@@ -252,15 +462,26 @@ func (b *ContainerBuilder) Port(containerPort, hostPort string) *ContainerBuilde
 
 // BindPort bind a Host port to a container port.
 func (b *ContainerBuilder) BindPort(containerPort, hostPort string) *ContainerBuilder {
-	b.HostConfig.PortBindings = nat.PortMap{
-		nat.Port(containerPort): []nat.PortBinding{
-			{HostIP: "0.0.0.0", HostPort: hostPort},
-		},
+	if b.HostConfig.PortBindings == nil {
+		b.HostConfig.PortBindings = nat.PortMap{}
+	}
+
+	b.HostConfig.PortBindings[nat.Port(containerPort)] = []nat.PortBinding{
+		{HostIP: "0.0.0.0", HostPort: hostPort},
 	}
 
 	return b
 }
 
+// addPortBinding adds a binding to the bindings of the container port, for example to bind it to several host IPs.
+func (b *ContainerBuilder) addPortBinding(containerPort nat.Port, binding nat.PortBinding) {
+	if b.HostConfig.PortBindings == nil {
+		b.HostConfig.PortBindings = nat.PortMap{}
+	}
+
+	b.HostConfig.PortBindings[containerPort] = append(b.HostConfig.PortBindings[containerPort], binding)
+}
+
 // ExposePort exposes a containers port inside the docker network - for example "80/tcp".
 func (b *ContainerBuilder) ExposePort(port string) *ContainerBuilder {
 	if b.ContainerConfig.ExposedPorts == nil {
@@ -283,21 +504,41 @@ func (b *ContainerBuilder) ensureNetworkConfig(n *Network) {
 }
 
 // IPAddress defines the IP address used by the container.
+// The address may be given relative to the networks subnet, for example ".10" for host 10.
+// If the relative address cannot be resolved, Build fails.
 func (b *ContainerBuilder) IPAddress(ipAddress string, n *Network) *ContainerBuilder {
-	if b.NetworkingConfig.EndpointsConfig == nil {
-		b.NetworkingConfig.EndpointsConfig = map[string]*dockerNetwork.EndpointSettings{}
-	}
+	b.ensureIPAMConfig(n)
 
-	if b.NetworkingConfig.EndpointsConfig[n.NetworkName] == nil {
-		endpointSetting := &dockerNetwork.EndpointSettings{
-			IPAMConfig: &dockerNetwork.EndpointIPAMConfig{IPv4Address: ipAddress},
-		}
-		b.NetworkingConfig.EndpointsConfig[n.NetworkName] = endpointSetting
-	} else {
-		b.NetworkingConfig.EndpointsConfig[n.NetworkName].IPAMConfig = &dockerNetwork.EndpointIPAMConfig{
-			IPv4Address: ipAddress,
-		}
+	resolved, err := n.resolveIP(ipAddress)
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("IP address of network '%s': %w", n.NetworkName, err)
 	}
 
+	b.NetworkingConfig.EndpointsConfig[n.NetworkName].IPAMConfig.IPv4Address = resolved
+
 	return b
 }
+
+// IPv6Address defines the IPv6 address used by the container.
+func (b *ContainerBuilder) IPv6Address(ipAddress string, n *Network) *ContainerBuilder {
+	b.ensureIPAMConfig(n)
+	b.NetworkingConfig.EndpointsConfig[n.NetworkName].IPAMConfig.IPv6Address = ipAddress
+
+	return b
+}
+
+// MacAddress defines the MAC address used by the container in the given Network.
+func (b *ContainerBuilder) MacAddress(macAddress string, n *Network) *ContainerBuilder {
+	b.ensureNetworkConfig(n)
+	b.NetworkingConfig.EndpointsConfig[n.NetworkName].MacAddress = macAddress
+
+	return b
+}
+
+func (b *ContainerBuilder) ensureIPAMConfig(n *Network) {
+	b.ensureNetworkConfig(n)
+
+	if b.NetworkingConfig.EndpointsConfig[n.NetworkName].IPAMConfig == nil {
+		b.NetworkingConfig.EndpointsConfig[n.NetworkName].IPAMConfig = &dockerNetwork.EndpointIPAMConfig{}
+	}
+}
@@ -0,0 +1,75 @@
+package dockertest
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	dockerNetwork "github.com/docker/docker/api/types/network"
+)
+
+func TestSplitEndpoints(t *testing.T) {
+	tests := []struct {
+		name           string
+		networkMode    string
+		networks       []string
+		wantPrimary    []string
+		wantAdditional []string
+		wantErr        error
+	}{
+		{name: "no network", networkMode: "default"},
+		{name: "single network", networkMode: "backend", networks: []string{"backend"}, wantPrimary: []string{"backend"}},
+		{
+			name: "several networks", networkMode: "backend", networks: []string{"backend", "frontend", "metrics"},
+			wantPrimary: []string{"backend"}, wantAdditional: []string{"frontend", "metrics"},
+		},
+		{
+			name: "primary network not connected", networkMode: "host", networks: []string{"backend", "frontend"},
+			wantErr: ErrPrimaryNetworkNotConnected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &ContainerBuilder{
+				HostConfig: &container.HostConfig{NetworkMode: container.NetworkMode(tt.networkMode)},
+				NetworkingConfig: &dockerNetwork.NetworkingConfig{
+					EndpointsConfig: map[string]*dockerNetwork.EndpointSettings{},
+				},
+			}
+
+			for _, network := range tt.networks {
+				b.NetworkingConfig.EndpointsConfig[network] = &dockerNetwork.EndpointSettings{}
+			}
+
+			networkingConfig, additionalEndpoints, err := b.splitEndpoints()
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if err != nil {
+				return
+			}
+
+			if got := networkNames(networkingConfig.EndpointsConfig); !slices.Equal(got, tt.wantPrimary) {
+				t.Fatalf("expected primary endpoints %v, got %v", tt.wantPrimary, got)
+			}
+
+			if got := networkNames(additionalEndpoints); !slices.Equal(got, tt.wantAdditional) {
+				t.Fatalf("expected additional endpoints %v, got %v", tt.wantAdditional, got)
+			}
+		})
+	}
+}
+
+func networkNames(endpoints map[string]*dockerNetwork.EndpointSettings) []string {
+	var names []string
+	for name := range endpoints {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
@@ -7,8 +7,10 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/docker/docker/pkg/stdcopy"
@@ -23,15 +25,25 @@ var ErrReadingContainerLog = errors.New("error reading container log")
 var ErrStateNotSet = errors.New("inspectJSON.State is nil")
 var ErrStateHealthNotSet = errors.New("inspectJSON.State.Health is nil")
 
-func dumpInspectContainter(ctx context.Context, dockerClient *client.Client, container *Container, logDir string) {
+func dumpInspectContainter(
+	ctx context.Context,
+	dockerClient *client.Client,
+	container *Container,
+	logDir string,
+	logger *slog.Logger,
+) {
 	inspectJSON, err := dockerClient.ContainerInspect(ctx, container.containerID)
 	if err != nil {
-		panicOnError(err)
+		logger.Error("could not inspect container",
+			operationAttr("dump inspect"), containerAttrs(container.Name, container.containerID), errorAttr(err))
+
+		return
 	}
 
 	b, err := json.Marshal(inspectJSON)
 	if err != nil {
-		fmt.Printf("error serializing inspect json for container '%s': %v\n", container.Name, err)
+		logger.Error("could not serialize inspect result",
+			operationAttr("dump inspect"), containerAttrs(container.Name, container.containerID), errorAttr(err))
 
 		return
 	}
@@ -41,16 +53,24 @@ func dumpInspectContainter(ctx context.Context, dockerClient *client.Client, con
 
 	err = os.WriteFile(logFilename, b, dumpFileMask)
 	if err != nil {
-		fmt.Printf("error writing inspect result to file '%s': %v\n", logFilename, err)
+		logger.Error("could not write inspect result", operationAttr("dump inspect"),
+			containerAttrs(container.Name, container.containerID), slog.String("file", logFilename), errorAttr(err))
 
 		return
 	}
 }
 
-func dumpContainerLog(ctx context.Context, dockerClient *client.Client, container *Container, logDir string) {
+func dumpContainerLog(
+	ctx context.Context,
+	dockerClient *client.Client,
+	container *Container,
+	logDir string,
+	logger *slog.Logger,
+) {
 	log, err := getContainerLog(ctx, dockerClient, container)
 	if err != nil {
-		fmt.Printf("error reading logs from container '%v: %v\n", container.Name, err)
+		logger.Error("could not read container log",
+			operationAttr("dump log"), containerAttrs(container.Name, container.containerID), errorAttr(err))
 
 		return
 	}
@@ -60,7 +80,8 @@ func dumpContainerLog(ctx context.Context, dockerClient *client.Client, containe
 
 	err = os.WriteFile(logFilename, log, dumpFileMask)
 	if err != nil {
-		fmt.Printf("error writing container log to file '%s': %v\n", logFilename, err)
+		logger.Error("could not write container log", operationAttr("dump log"),
+			containerAttrs(container.Name, container.containerID), slog.String("file", logFilename), errorAttr(err))
 
 		return
 	}
@@ -70,10 +91,12 @@ func dumpContainerHealthCheckLog(ctx context.Context,
 	dockerClient *client.Client,
 	container *Container,
 	logDir string,
+	logger *slog.Logger,
 ) {
 	log, err := getContainerHealthCheckLog(ctx, dockerClient, container)
 	if err != nil {
-		fmt.Printf("error reading healtcheck logs from container '%v: %v\n", container.Name, err)
+		logger.Error("could not read health check log",
+			operationAttr("dump health check log"), containerAttrs(container.Name, container.containerID), errorAttr(err))
 
 		return
 	}
@@ -83,7 +106,8 @@ func dumpContainerHealthCheckLog(ctx context.Context,
 
 	err = os.WriteFile(logFilename, log, dumpFileMask)
 	if err != nil {
-		fmt.Printf("error writing container healtcheck log to file '%s': %v\n", logFilename, err)
+		logger.Error("could not write health check log", operationAttr("dump health check log"),
+			containerAttrs(container.Name, container.containerID), slog.String("file", logFilename), errorAttr(err))
 
 		return
 	}
@@ -146,7 +170,35 @@ func getContainerLog(ctx context.Context, dockerClient *client.Client, container
 	return log.Bytes(), nil
 }
 
-func writeLog(w io.Writer, c *Container, log []byte) {
+// containerLogTail returns the last lines of the containers log, errors are returned as the log content.
+func containerLogTail(dockerClient *client.Client, container *Container, lines int) string {
+	ctx, cancel := context.WithTimeout(context.Background(), cleanerTimeout)
+	defer cancel()
+
+	logReader, err := dockerClient.ContainerLogs(
+		ctx,
+		container.containerID,
+		types.ContainerLogsOptions{ShowStderr: true, ShowStdout: true, Tail: strconv.Itoa(lines)},
+	)
+	if err != nil {
+		return fmt.Sprintf("%v: %v", ErrReadingContainerLog, err)
+	}
+
+	defer func() {
+		_ = logReader.Close()
+	}()
+
+	var log = bytes.NewBufferString("")
+
+	_, err = stdcopy.StdCopy(log, log, logReader)
+	if err != nil {
+		return fmt.Sprintf("%v: %v", ErrReadingContainerLog, err)
+	}
+
+	return log.String()
+}
+
+func writeLog(w io.Writer, c *Container, log []byte, logger *slog.Logger) {
 	writes := []func() (n int, err error){
 		func() (n int, err error) {
 			return w.Write([]byte(fmt.Sprintf("\n------ Container Log '%s':\n", c.Name)))
@@ -160,7 +212,8 @@ func writeLog(w io.Writer, c *Container, log []byte) {
 	for _, write := range writes {
 		_, err := write()
 		if err != nil {
-			fmt.Printf("error writing container '%s' log: %v", c.Name, err)
+			logger.Error("could not write container log",
+				operationAttr("write logs"), containerAttrs(c.Name, c.containerID), errorAttr(err))
 
 			return
 		}
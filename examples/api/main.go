@@ -48,6 +48,7 @@ func main() {
 	// create the API container, the system under test
 	api, err := basicConfiguration.NewContainerBuilder().
 		Name("api").
+		NetworkAliases(net, "api").
 		Cmd("go run nameapi/main.go").
 		Env("API_BASE_URL", "http://localhost:8080").
 		HealthShellCmd("go run healthcheck/main.go").
@@ -58,7 +59,6 @@ func main() {
 	tests, err := basicConfiguration.NewContainerBuilder().
 		Name("tests").
 		Cmd("go test -v tests/api_test.go").
-		Link(api, "api", net).
 		Env("API_BASE_URL", "http://api:8080").
 		Build()
 	panicOnErr(err)
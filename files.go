@@ -0,0 +1,148 @@
+package dockertest
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/client"
+)
+
+// ErrFileNotFound is returned if a path does not exist in the container.
+var ErrFileNotFound = errors.New("file not found in container")
+
+// ErrNotARegularFile is returned from ReadFile for directories and other non-regular files.
+var ErrNotARegularFile = errors.New("not a regular file")
+
+// ErrTooManyLinks is returned from ReadFile if resolving symbolic links did not end in a file.
+var ErrTooManyLinks = errors.New("too many levels of symbolic links")
+
+// maxLinkDepth is the number of symbolic links ReadFile follows, like the limit of the linux kernel.
+const maxLinkDepth = 40
+
+// FilesystemDiff lists the paths changed in a container compared to its image.
+type FilesystemDiff struct {
+	Added   []string
+	Changed []string
+	Deleted []string
+}
+
+// FileInfo describes a file in a container.
+type FileInfo struct {
+	Name    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+	// LinkTarget is the path a symbolic link points to.
+	LinkTarget string
+}
+
+// Diff returns the paths that were added, changed or deleted in the container since it was created from its image.
+// A changed directory is listed for every change inside of it. It works on stopped containers as well.
+func (c Container) Diff(ctx context.Context) (FilesystemDiff, error) {
+	changes, err := c.dockerClient.ContainerDiff(ctx, c.containerID)
+	if err != nil {
+		return FilesystemDiff{}, err
+	}
+
+	var diff FilesystemDiff
+
+	for _, change := range changes {
+		switch change.Kind {
+		case container.ChangeAdd:
+			diff.Added = append(diff.Added, change.Path)
+		case container.ChangeModify:
+			diff.Changed = append(diff.Changed, change.Path)
+		case container.ChangeDelete:
+			diff.Deleted = append(diff.Deleted, change.Path)
+		}
+	}
+
+	return diff, nil
+}
+
+// Stat returns information about the file at the given path, symbolic links are not followed.
+// It fails with ErrFileNotFound if the path does not exist. It works on stopped containers as well.
+func (c Container) Stat(ctx context.Context, path string) (FileInfo, error) {
+	stat, err := c.dockerClient.ContainerStatPath(ctx, c.containerID, path)
+	if err != nil {
+		return FileInfo{}, c.fileError(ctx, path, err)
+	}
+
+	return FileInfo{
+		Name:       stat.Name,
+		Size:       stat.Size,
+		Mode:       stat.Mode,
+		ModTime:    stat.Mtime,
+		LinkTarget: stat.LinkTarget,
+	}, nil
+}
+
+// FileExists returns whether the path exists in the container. It works on stopped containers as well.
+func (c Container) FileExists(ctx context.Context, path string) (bool, error) {
+	_, err := c.Stat(ctx, path)
+	if errors.Is(err, ErrFileNotFound) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// ReadFile returns the content of the regular file at the given path, symbolic links are followed.
+// It fails with ErrFileNotFound if the path does not exist. It works on stopped containers as well.
+func (c Container) ReadFile(ctx context.Context, path string) ([]byte, error) {
+	return c.readFile(ctx, path, 0)
+}
+
+func (c Container) readFile(ctx context.Context, path string, linkDepth int) ([]byte, error) {
+	reader, stat, err := c.dockerClient.CopyFromContainer(ctx, c.containerID, path)
+	if err != nil {
+		return nil, c.fileError(ctx, path, err)
+	}
+
+	defer func() {
+		_ = reader.Close()
+	}()
+
+	if stat.Mode&os.ModeSymlink != 0 && stat.LinkTarget != "" && stat.LinkTarget != path {
+		if linkDepth == maxLinkDepth {
+			return nil, fmt.Errorf("%w: '%s' in container '%s'", ErrTooManyLinks, path, c.Name)
+		}
+
+		return c.readFile(ctx, stat.LinkTarget, linkDepth+1)
+	}
+
+	if !stat.Mode.IsRegular() {
+		return nil, fmt.Errorf("%w: '%s' in container '%s'", ErrNotARegularFile, path, c.Name)
+	}
+
+	tr := tar.NewReader(reader)
+
+	_, err = tr.Next()
+	if err != nil {
+		return nil, fmt.Errorf("error reading '%s' from container '%s': %w", path, c.Name, err)
+	}
+
+	return io.ReadAll(tr)
+}
+
+// fileError wraps not found errors of the archive API with ErrFileNotFound.
+// The archive API reports a missing container and a missing path alike, so the container is inspected
+// to tell them apart.
+func (c Container) fileError(ctx context.Context, path string, err error) error {
+	if !client.IsErrNotFound(err) {
+		return err
+	}
+
+	_, inspectErr := c.dockerClient.ContainerInspect(ctx, c.containerID)
+	if inspectErr != nil {
+		return fmt.Errorf("%w: %w", ErrInspectingContainer, inspectErr)
+	}
+
+	return fmt.Errorf("%w: '%s' in container '%s'", ErrFileNotFound, path, c.Name)
+}
@@ -6,6 +6,7 @@ require (
 	github.com/docker/docker v24.0.7+incompatible
 	github.com/docker/go-connections v0.4.0
 	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
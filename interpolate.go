@@ -0,0 +1,177 @@
+package dockertest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrMissingVariable is returned by ${VAR:?message} and ${VAR?message} expressions if the variable is not set.
+var ErrMissingVariable = errors.New("required variable is not set")
+
+// ErrInvalidInterpolation is returned for malformed ${...} expressions.
+var ErrInvalidInterpolation = errors.New("invalid variable expression")
+
+type lookupFunc func(name string) (string, bool)
+
+// interpolate replaces ${VAR} expressions by the looked up values, $$ is replaced by a literal $.
+// Any other $ is kept, so shell snippets like $1 or $HOSTNAME in commands pass through unchanged.
+// Expressions support the modifiers of POSIX shells:
+//
+//	${VAR:-default} default if VAR is unset or empty, ${VAR-default} default if VAR is unset
+//	${VAR:?message} error if VAR is unset or empty, ${VAR?message} error if VAR is unset
+//	${VAR:+value}   value if VAR is set and not empty, ${VAR+value} value if VAR is set
+func interpolate(s string, lookup lookupFunc) (string, error) {
+	return expandVariables(s, lookup, false)
+}
+
+func expandVariables(s string, lookup lookupFunc, bare bool) (string, error) {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+
+			continue
+		}
+
+		next := s[i+1]
+
+		switch {
+		case next == '$':
+			sb.WriteByte('$')
+			i++
+		case next == '{':
+			end, err := closingBrace(s, i+2)
+			if err != nil {
+				return "", err
+			}
+
+			value, err := expandExpression(s[i+2:end], lookup, bare)
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteString(value)
+			i = end
+		case bare && isNameStart(next):
+			end := i + 1
+			for end < len(s) && isNameChar(s[end]) {
+				end++
+			}
+
+			value, _ := lookup(s[i+1 : end])
+			sb.WriteString(value)
+			i = end - 1
+		default:
+			sb.WriteByte('$')
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// closingBrace returns the index of the brace closing the expression starting at the given index,
+// nested expressions in default values are skipped.
+func closingBrace(s string, start int) (int, error) {
+	depth := 1
+
+	for i := start; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}':
+			depth--
+			if depth == 0 {
+				return i, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("%w: unterminated '%s'", ErrInvalidInterpolation, excerpt(s[start-2:]))
+}
+
+func expandExpression(expr string, lookup lookupFunc, bare bool) (string, error) {
+	nameEnd := 0
+	for nameEnd < len(expr) && isNameChar(expr[nameEnd]) {
+		nameEnd++
+	}
+
+	name, modifier := expr[:nameEnd], expr[nameEnd:]
+	if name == "" || !isNameStart(name[0]) {
+		return "", fmt.Errorf("%w: '${%s}'", ErrInvalidInterpolation, expr)
+	}
+
+	value, ok := lookup(name)
+	if modifier == "" {
+		return value, nil
+	}
+
+	// the colon variants treat empty values like unset ones.
+	set := ok
+	if strings.HasPrefix(modifier, ":") {
+		set = ok && value != ""
+		modifier = modifier[1:]
+	}
+
+	if modifier == "" {
+		return "", fmt.Errorf("%w: '${%s}'", ErrInvalidInterpolation, expr)
+	}
+
+	// the word is only expanded if it is used, so required variables in an unused default do not fail.
+	word := func() (string, error) {
+		return expandVariables(modifier[1:], lookup, bare)
+	}
+
+	switch modifier[0] {
+	case '-':
+		if set {
+			return value, nil
+		}
+
+		return word()
+	case '?':
+		if set {
+			return value, nil
+		}
+
+		message, err := word()
+		if err != nil {
+			return "", err
+		}
+
+		return "", fmt.Errorf("%w '%s': %s", ErrMissingVariable, name, message)
+	case '+':
+		if set {
+			return word()
+		}
+
+		return "", nil
+	default:
+		return "", fmt.Errorf("%w: '${%s}'", ErrInvalidInterpolation, expr)
+	}
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+// excerpt shortens the text for error messages.
+func excerpt(s string) string {
+	const maxLen = 40
+
+	if line, _, found := strings.Cut(s, "\n"); found {
+		s = line
+	}
+
+	if len(s) > maxLen {
+		return s[:maxLen] + "..."
+	}
+
+	return s
+}
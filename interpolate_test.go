@@ -0,0 +1,67 @@
+package dockertest
+
+import (
+	"errors"
+	"testing"
+)
+
+func testLookup(vars map[string]string) lookupFunc {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+
+		return v, ok
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	vars := map[string]string{"IMAGE": "golang:1.21", "EMPTY": "", "PORT": "8080"}
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{name: "no variables", input: "image: busybox", want: "image: busybox"},
+		{name: "braced variable", input: "image: ${IMAGE}", want: "image: golang:1.21"},
+		{name: "unset variable", input: "image: ${UNSET}", want: "image: "},
+		{name: "bare variable is kept", input: "cmd: echo $HOSTNAME", want: "cmd: echo $HOSTNAME"},
+		{name: "positional parameter is kept", input: "cmd: sh -c 'echo $1'", want: "cmd: sh -c 'echo $1'"},
+		{name: "escaped dollar", input: "cmd: echo $$HOSTNAME", want: "cmd: echo $HOSTNAME"},
+		{name: "escaped braces", input: "cmd: echo $${IMAGE}", want: "cmd: echo ${IMAGE}"},
+		{name: "trailing dollar", input: "price: 5$", want: "price: 5$"},
+		{name: "default for unset", input: "${UNSET:-busybox}", want: "busybox"},
+		{name: "default for empty", input: "${EMPTY:-busybox}", want: "busybox"},
+		{name: "default not used", input: "${IMAGE:-busybox}", want: "golang:1.21"},
+		{name: "dash default keeps empty", input: "${EMPTY-busybox}", want: ""},
+		{name: "dash default for unset", input: "${UNSET-busybox}", want: "busybox"},
+		{name: "nested default", input: "${UNSET:-${PORT}}", want: "8080"},
+		{name: "default with colon", input: "${UNSET:-localhost:80}", want: "localhost:80"},
+		{name: "alternative value", input: "${PORT:+set}", want: "set"},
+		{name: "alternative for empty", input: "${EMPTY:+set}", want: ""},
+		{name: "required is set", input: "${PORT:?port required}", want: "8080"},
+		{name: "required unset", input: "${UNSET:?port required}", wantErr: ErrMissingVariable},
+		{name: "required empty", input: "${EMPTY:?port required}", wantErr: ErrMissingVariable},
+		{name: "required without colon allows empty", input: "${EMPTY?port required}", want: ""},
+		{name: "unused required default", input: "${PORT:-${UNSET:?port required}}", want: "8080"},
+		{name: "used required default", input: "${UNSET:-${UNSET:?port required}}", wantErr: ErrMissingVariable},
+		{name: "unused alternative", input: "${UNSET:+${UNSET:?port required}}", want: ""},
+		{name: "message with variable", input: "${UNSET:?set ${PORT}}", wantErr: ErrMissingVariable},
+		{name: "unterminated", input: "image: ${IMAGE", wantErr: ErrInvalidInterpolation},
+		{name: "empty name", input: "${}", wantErr: ErrInvalidInterpolation},
+		{name: "invalid modifier", input: "${IMAGE/a/b}", wantErr: ErrInvalidInterpolation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := interpolate(tt.input, testLookup(vars))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("expected '%s', got '%s'", tt.want, got)
+			}
+		})
+	}
+}
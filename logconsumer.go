@@ -0,0 +1,317 @@
+package dockertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLogPatternMatched is returned from LogWatchdog.Err when a log line matched the watched pattern.
+var ErrLogPatternMatched = errors.New("log line matched failure pattern")
+
+const logFileMask = 0644
+
+// LogStream names the output stream a log line was written to.
+type LogStream string
+
+// Log streams of a container.
+const (
+	Stdout LogStream = "stdout"
+	Stderr LogStream = "stderr"
+)
+
+// LogLine is a single line of a containers log output.
+type LogLine struct {
+	Container string
+	Stream    LogStream
+	Timestamp time.Time
+	Text      string
+}
+
+func (l LogLine) String() string {
+	return fmt.Sprintf("%s [%s] %s", l.Timestamp.Format(time.RFC3339Nano), l.Container, l.Text)
+}
+
+// LogConsumer receives the log lines of containers while they are running.
+// Consume may be called concurrently for different containers and streams.
+type LogConsumer interface {
+	Consume(line LogLine)
+}
+
+// LogConsumerFunc adapts a function to a LogConsumer.
+type LogConsumerFunc func(line LogLine)
+
+// Consume calls f(line).
+func (f LogConsumerFunc) Consume(line LogLine) {
+	f(line)
+}
+
+// LogConsumer registers consumers receiving the log lines of the container once it is started.
+func (b *ContainerBuilder) LogConsumer(consumers ...LogConsumer) *ContainerBuilder {
+	b.logConsumers = append(b.logConsumers, consumers...)
+
+	return b
+}
+
+// AddLogConsumer registers consumers receiving the log lines of all containers of the session
+// which are started afterwards.
+func (dt *Session) AddLogConsumer(consumers ...LogConsumer) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	dt.logConsumers = append(dt.logConsumers, consumers...)
+}
+
+// Context returns the sessions context, it is cancelled by Session.Cancel or a LogWatchdog.
+func (dt *Session) Context() context.Context {
+	return dt.ctx
+}
+
+// consumeLogs follows the containers log and passes every line to the consumers of the container and the session.
+func (dt *Session) consumeLogs(c *Container) {
+	dt.mu.Lock()
+	consumers := append(append([]LogConsumer{}, dt.logConsumers...), c.logConsumers...)
+	dt.mu.Unlock()
+
+	if len(consumers) == 0 {
+		return
+	}
+
+	dispatch := func(stream LogStream) *lineWriter {
+		return &lineWriter{emit: func(line string) {
+			logLine := parseLogLine(c.Name, stream, line)
+			for _, consumer := range consumers {
+				consumer.Consume(logLine)
+			}
+		}}
+	}
+
+	go func() {
+		err := c.FollowLogs(dt.ctx, dispatch(Stdout), dispatch(Stderr), LogOptions{Timestamps: true})
+		if err != nil && dt.ctx.Err() == nil {
+			dt.logger.Error("could not follow container log",
+				operationAttr("consume logs"), containerAttrs(c.Name, c.containerID), errorAttr(err))
+		}
+	}()
+}
+
+// parseLogLine splits the timestamp docker prefixes log lines with from the text.
+func parseLogLine(containerName string, stream LogStream, line string) LogLine {
+	logLine := LogLine{Container: containerName, Stream: stream, Text: line}
+
+	if timestamp, text, ok := strings.Cut(line, " "); ok {
+		if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+			logLine.Timestamp = t
+			logLine.Text = text
+		}
+	}
+
+	return logLine
+}
+
+// LogRingBuffer keeps the last log lines it consumed, for example to add them to error messages.
+type LogRingBuffer struct {
+	mu    sync.Mutex
+	lines []LogLine
+	next  int
+	full  bool
+}
+
+// NewLogRingBuffer returns a LogRingBuffer keeping the last size lines.
+func NewLogRingBuffer(size int) *LogRingBuffer {
+	if size < 1 {
+		size = 1
+	}
+
+	return &LogRingBuffer{lines: make([]LogLine, size)}
+}
+
+// Consume stores the line, replacing the oldest one if the buffer is full.
+func (r *LogRingBuffer) Consume(line LogLine) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lines[r.next] = line
+	r.next = (r.next + 1) % len(r.lines)
+
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Lines returns the buffered lines, oldest first.
+func (r *LogRingBuffer) Lines() []LogLine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]LogLine{}, r.lines[:r.next]...)
+	}
+
+	return append(append([]LogLine{}, r.lines[r.next:]...), r.lines[:r.next]...)
+}
+
+// String returns the buffered lines, one per line.
+func (r *LogRingBuffer) String() string {
+	var sb strings.Builder
+
+	for _, line := range r.Lines() {
+		sb.WriteString(line.String())
+		sb.WriteString("\n")
+	}
+
+	return sb.String()
+}
+
+// LogWatchdog cancels the sessions context as soon as a log line matches its pattern.
+type LogWatchdog struct {
+	pattern *regexp.Regexp
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	matched *LogLine
+}
+
+// FailOnLogPattern returns a LogWatchdog for the session, which cancels the session context
+// when a consumed line matches the pattern, for example a panic in one of the containers.
+// That ends the waits and operations using the session context, while the Dump functions
+// and cleanups keep working to collect the diagnostics.
+// Register it on the session or on single container builders.
+func (dt *Session) FailOnLogPattern(pattern *regexp.Regexp) *LogWatchdog {
+	return &LogWatchdog{pattern: pattern, cancel: dt.cancelCtx}
+}
+
+// Consume cancels the session context if the line matches the pattern, only the first match is recorded.
+func (w *LogWatchdog) Consume(line LogLine) {
+	if !w.pattern.MatchString(line.Text) {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.matched == nil {
+		w.matched = &line
+		w.cancel()
+	}
+}
+
+// Err returns an ErrLogPatternMatched error naming the first matching line, or nil if no line matched.
+func (w *LogWatchdog) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.matched == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%w '%s' in %s of container '%s': %s",
+		ErrLogPatternMatched, w.pattern, w.matched.Stream, w.matched.Container, w.matched.Text)
+}
+
+// FileLogConsumer writes consumed log lines to a file, which is rotated when it exceeds its maximum size.
+// The rotated files are named like the file with a numeric suffix, ".1" being the most recent.
+type FileLogConsumer struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	err        error
+}
+
+// NewFileLogConsumer creates the log file at path. A maxSize of 0 disables rotation,
+// maxBackups limits the number of rotated files kept.
+func NewFileLogConsumer(path string, maxSize int64, maxBackups int) (*FileLogConsumer, error) {
+	f := &FileLogConsumer{path: path, maxSize: maxSize, maxBackups: maxBackups}
+
+	err := f.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Consume appends the line to the log file. Since consumers cannot fail, the first write error is returned by Close.
+func (f *FileLogConsumer) Consume(line LogLine) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil || f.err != nil {
+		return
+	}
+
+	text := fmt.Sprintf("%s %s [%s] %s\n", line.Timestamp.Format(time.RFC3339Nano), line.Container, line.Stream, line.Text)
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(text)) > f.maxSize {
+		f.err = f.rotate()
+		if f.err != nil {
+			return
+		}
+	}
+
+	n, err := f.file.WriteString(text)
+	f.size += int64(n)
+	f.err = err
+}
+
+// Close closes the log file, lines consumed afterwards are dropped.
+// It returns the first error that occurred while writing or rotating the file.
+func (f *FileLogConsumer) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return f.err
+	}
+
+	err := f.file.Close()
+	f.file = nil
+
+	return errors.Join(f.err, err)
+}
+
+func (f *FileLogConsumer) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, logFileMask)
+	if err != nil {
+		return err
+	}
+
+	f.file = file
+	f.size = 0
+
+	return nil
+}
+
+func (f *FileLogConsumer) rotate() error {
+	err := f.file.Close()
+	if err != nil {
+		return err
+	}
+
+	f.file = nil
+
+	if f.maxBackups < 1 {
+		return f.open()
+	}
+
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		err = os.Rename(fmt.Sprintf("%s.%v", f.path, i), fmt.Sprintf("%s.%v", f.path, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	err = os.Rename(f.path, f.path+".1")
+	if err != nil {
+		return err
+	}
+
+	return f.open()
+}
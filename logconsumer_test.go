@@ -0,0 +1,201 @@
+package dockertest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogRingBuffer(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		consumed int
+		want     []string
+	}{
+		{name: "empty", size: 3},
+		{name: "not full", size: 3, consumed: 2, want: []string{"0", "1"}},
+		{name: "full", size: 3, consumed: 3, want: []string{"0", "1", "2"}},
+		{name: "wrapped around", size: 3, consumed: 5, want: []string{"2", "3", "4"}},
+		{name: "wrapped around twice", size: 3, consumed: 7, want: []string{"4", "5", "6"}},
+		{name: "minimum size", size: 0, consumed: 2, want: []string{"1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := NewLogRingBuffer(tt.size)
+			for i := 0; i < tt.consumed; i++ {
+				buffer.Consume(LogLine{Text: fmt.Sprint(i)})
+			}
+
+			var got []string
+			for _, line := range buffer.Lines() {
+				got = append(got, line.Text)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+
+			if lines := strings.Count(buffer.String(), "\n"); lines != len(tt.want) {
+				t.Fatalf("expected %v lines in String, got %v", len(tt.want), lines)
+			}
+		})
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		want   []string
+		flush  []string
+	}{
+		{name: "single line", writes: []string{"a\n"}, want: []string{"a"}},
+		{name: "several lines in one write", writes: []string{"a\nb\n"}, want: []string{"a", "b"}},
+		{name: "line split across writes", writes: []string{"a", "b", "c\n"}, want: []string{"abc"}},
+		{name: "partial line is buffered", writes: []string{"a\nb"}, want: []string{"a"}, flush: []string{"b"}},
+		{name: "carriage return is trimmed", writes: []string{"a\r\n"}, want: []string{"a"}},
+		{name: "empty lines", writes: []string{"\n\n"}, want: []string{"", ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+
+			w := &lineWriter{emit: func(line string) {
+				got = append(got, line)
+			}}
+
+			for _, s := range tt.writes {
+				n, err := w.Write([]byte(s))
+				if err != nil || n != len(s) {
+					t.Fatalf("expected %v bytes written without error, got %v, %v", len(s), n, err)
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+
+			got = nil
+
+			flushLines(w)
+
+			if !reflect.DeepEqual(got, tt.flush) {
+				t.Fatalf("expected flush to emit %v, got %v", tt.flush, got)
+			}
+		})
+	}
+}
+
+func TestParseLogLine(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+	tests := []struct {
+		name string
+		line string
+		want LogLine
+	}{
+		{
+			name: "timestamp",
+			line: timestamp.Format(time.RFC3339Nano) + " hello world",
+			want: LogLine{Container: "db", Stream: Stderr, Timestamp: timestamp, Text: "hello world"},
+		},
+		{
+			name: "no timestamp",
+			line: "hello world",
+			want: LogLine{Container: "db", Stream: Stderr, Text: "hello world"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseLogLine("db", Stderr, tt.line)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFileLogConsumer(t *testing.T) {
+	line := LogLine{Container: "db", Stream: Stdout, Text: "0123456789"}
+	lineSize := int64(len(fmt.Sprintf("%s %s [%s] %s\n",
+		line.Timestamp.Format(time.RFC3339Nano), line.Container, line.Stream, line.Text)))
+
+	tests := []struct {
+		name       string
+		maxSize    int64
+		maxBackups int
+		consumed   int
+		wantFiles  map[string]int
+	}{
+		{name: "no rotation", consumed: 5, wantFiles: map[string]int{"": 5}},
+		{name: "below max size", maxSize: 3 * lineSize, consumed: 3, wantFiles: map[string]int{"": 3}},
+		{
+			name: "rotated", maxSize: 2 * lineSize, maxBackups: 3, consumed: 5,
+			wantFiles: map[string]int{"": 1, ".1": 2, ".2": 2},
+		},
+		{
+			name: "backups are limited", maxSize: lineSize, maxBackups: 2, consumed: 5,
+			wantFiles: map[string]int{"": 1, ".1": 1, ".2": 1},
+		},
+		{name: "no backups", maxSize: 2 * lineSize, consumed: 5, wantFiles: map[string]int{"": 1}},
+		{name: "line larger than max size", maxSize: 1, consumed: 2, wantFiles: map[string]int{"": 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "container.log")
+
+			consumer, err := NewFileLogConsumer(path, tt.maxSize, tt.maxBackups)
+			if err != nil {
+				t.Fatalf("did not expect an error, got %v", err)
+			}
+
+			for i := 0; i < tt.consumed; i++ {
+				consumer.Consume(line)
+			}
+
+			err = consumer.Close()
+			if err != nil {
+				t.Fatalf("did not expect an error, got %v", err)
+			}
+
+			consumer.Consume(line)
+
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("did not expect an error, got %v", err)
+			}
+
+			if len(entries) != len(tt.wantFiles) {
+				t.Fatalf("expected %v files, got %v", len(tt.wantFiles), len(entries))
+			}
+
+			for suffix, wantLines := range tt.wantFiles {
+				content, err := os.ReadFile(path + suffix)
+				if err != nil {
+					t.Fatalf("did not expect an error, got %v", err)
+				}
+
+				if lines := strings.Count(string(content), "\n"); lines != wantLines {
+					t.Fatalf("expected %v lines in '%s', got %v", wantLines, path+suffix, lines)
+				}
+			}
+		})
+	}
+}
+
+func TestNewFileLogConsumerError(t *testing.T) {
+	_, err := NewFileLogConsumer(filepath.Join(t.TempDir(), "missing", "container.log"), 0, 0)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
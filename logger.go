@@ -0,0 +1,43 @@
+package dockertest
+
+import (
+	"io"
+	"log/slog"
+	"math"
+	"os"
+)
+
+// WithLogger sets the logger receiving the sessions diagnostics, like errors during cleanup.
+// By default diagnostics are written as text to stderr, nil discards them.
+// All records carry the session ID, records concerning a container also its name and ID.
+func WithLogger(logger *slog.Logger) SessionOption {
+	return func(dt *Session) {
+		if logger == nil {
+			logger = newDiscardLogger()
+		}
+
+		dt.logger = logger
+	}
+}
+
+func newDefaultLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(os.Stderr, nil))
+}
+
+// newDiscardLogger returns a logger with all levels disabled, so records are not even formatted.
+func newDiscardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.Level(math.MaxInt)}))
+}
+
+// containerAttrs returns the attributes identifying a container in log records.
+func containerAttrs(name, id string) slog.Attr {
+	return slog.Group("container", slog.String("name", name), slog.String("id", id))
+}
+
+func operationAttr(operation string) slog.Attr {
+	return slog.String("operation", operation)
+}
+
+func errorAttr(err error) slog.Attr {
+	return slog.Any("error", err)
+}
@@ -0,0 +1,36 @@
+package dockertest
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestWithLogger(t *testing.T) {
+	logger := slog.Default()
+
+	tests := []struct {
+		name   string
+		logger *slog.Logger
+		want   func(*slog.Logger) bool
+	}{
+		{name: "logger", logger: logger, want: func(got *slog.Logger) bool { return got == logger }},
+		{
+			name: "nil discards", logger: nil,
+			want: func(got *slog.Logger) bool { return got != nil && !got.Enabled(context.Background(), slog.LevelError) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dt := &Session{}
+			WithLogger(tt.logger)(dt)
+
+			if !tt.want(dt.logger) {
+				t.Fatalf("unexpected logger %v", dt.logger)
+			}
+
+			dt.logger.With(slog.String("session", "test")).Error("must not panic")
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package dockertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// consoleColors are the ANSI colors used to tell containers apart in the console log stream.
+var consoleColors = []string{"\033[36m", "\033[33m", "\033[32m", "\033[35m", "\033[34m", "\033[91m", "\033[96m"}
+
+const consoleColorReset = "\033[0m"
+
+// LogOptions selects the log output of Container.FollowLogs.
+type LogOptions struct {
+	// Since shows only logs written after the given time.
+	Since time.Time
+	// Until stops following at the given time.
+	Until time.Time
+	// Timestamps prefixes every line with its timestamp.
+	Timestamps bool
+	// Tail shows only the given number of lines from the end of the existing logs, for example "10", default is "all".
+	Tail string
+}
+
+// TestLogger is the part of testing.TB used by NewTestLogWriter.
+type TestLogger interface {
+	Helper()
+	Log(args ...interface{})
+}
+
+// SessionOption configures a Session on creation.
+type SessionOption func(dt *Session)
+
+// WithConsoleLogs tees the logs of all containers started in the session to w.
+// The lines of all containers are interleaved, prefixed with the container name and colorised per container.
+func WithConsoleLogs(w io.Writer) SessionOption {
+	return func(dt *Session) {
+		dt.console = &console{w: w, followed: map[string]bool{}}
+	}
+}
+
+// FollowLogs copies the containers stdout and stderr to the given writers until the container stops,
+// the context is done or the Until time of the options is reached.
+func (c Container) FollowLogs(ctx context.Context, stdout, stderr io.Writer, opts LogOptions) error {
+	logReader, err := c.dockerClient.ContainerLogs(ctx, c.containerID, opts.containerLogsOptions())
+	if err != nil {
+		return fmt.Errorf("%w for '%s': %w", ErrReadingContainerLog, c.Name, err)
+	}
+
+	defer func() {
+		_ = logReader.Close()
+	}()
+
+	_, err = stdcopy.StdCopy(stdout, stderr, logReader)
+
+	flushLines(stdout)
+	flushLines(stderr)
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if err != nil {
+		return fmt.Errorf("%w stream for '%s': %w", ErrReadingContainerLog, c.Name, err)
+	}
+
+	return nil
+}
+
+// NewTestLogWriter returns a writer that logs every line to t, prefixed with the containers name.
+// It is meant to be passed to FollowLogs, which must be stopped before the test ends.
+func NewTestLogWriter(t TestLogger, c *Container) io.Writer {
+	return &lineWriter{emit: func(line string) {
+		t.Helper()
+		t.Log(fmt.Sprintf("[%s] %s", c.Name, line))
+	}}
+}
+
+func (o LogOptions) containerLogsOptions() types.ContainerLogsOptions {
+	options := types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+		Timestamps: o.Timestamps,
+		Tail:       o.Tail,
+	}
+
+	if !o.Since.IsZero() {
+		options.Since = o.Since.Format(time.RFC3339Nano)
+	}
+
+	if !o.Until.IsZero() {
+		options.Until = o.Until.Format(time.RFC3339Nano)
+	}
+
+	return options
+}
+
+// lineWriter calls emit for every complete line written to it.
+type lineWriter struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+	emit   func(line string)
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buffer.Write(p)
+
+	for {
+		i := bytes.IndexByte(w.buffer.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+
+		line := w.buffer.Next(i + 1)
+		w.emit(string(bytes.TrimRight(line, "\r\n")))
+	}
+}
+
+// flush emits a remaining incomplete line.
+func (w *lineWriter) flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.buffer.Len() > 0 {
+		w.emit(w.buffer.String())
+		w.buffer.Reset()
+	}
+}
+
+func flushLines(w io.Writer) {
+	if lw, ok := w.(*lineWriter); ok {
+		lw.flush()
+	}
+}
+
+// console interleaves the log lines of all containers of a session on one writer.
+type console struct {
+	mu       sync.Mutex
+	w        io.Writer
+	followed map[string]bool
+}
+
+func (cs *console) follow(ctx context.Context, c *Container) {
+	cs.mu.Lock()
+
+	if cs.followed[c.containerID] {
+		cs.mu.Unlock()
+
+		return
+	}
+
+	color := consoleColors[len(cs.followed)%len(consoleColors)]
+	cs.followed[c.containerID] = true
+	cs.mu.Unlock()
+
+	writer := &lineWriter{emit: func(line string) {
+		cs.mu.Lock()
+		defer cs.mu.Unlock()
+
+		_, _ = fmt.Fprintf(cs.w, "%s%s |%s %s\n", color, c.Name, consoleColorReset, line)
+	}}
+
+	go func() {
+		err := c.FollowLogs(ctx, writer, writer, LogOptions{})
+		if err != nil && ctx.Err() == nil {
+			c.logger.Error("could not follow container log",
+				operationAttr("console logs"), containerAttrs(c.Name, c.containerID), errorAttr(err))
+		}
+	}()
+}
@@ -0,0 +1,208 @@
+package dockertest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	dockerNetwork "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/errdefs"
+)
+
+// NameConflictPolicy tells Build what to do if a container with the same name exists already.
+type NameConflictPolicy int
+
+const (
+	// NameConflictFail makes Build fail with a *NameConflictError, it is the default.
+	NameConflictFail NameConflictPolicy = iota
+	// NameConflictRemove removes the existing container if it carries the dockertest labels,
+	// for example after a crashed test run. Other containers are left alone and Build fails,
+	// as well as reusable containers and containers of the same session, which may still be in use.
+	NameConflictRemove
+	// NameConflictUniqueSuffix appends a counter to the name, until it is unique. Build fails if no name
+	// up to the suffix -100 is free.
+	NameConflictUniqueSuffix
+)
+
+// maxNameSuffix is the highest counter NameConflictUniqueSuffix tries.
+const maxNameSuffix = 100
+
+func (p NameConflictPolicy) String() string {
+	switch p {
+	case NameConflictRemove:
+		return "remove"
+	case NameConflictUniqueSuffix:
+		return "unique suffix"
+	default:
+		return "fail"
+	}
+}
+
+// NameConflictError is returned from Build if a container with the same name exists already
+// and the conflict could not be resolved. It matches ErrNameConflict.
+type NameConflictError struct {
+	Name   string
+	Policy NameConflictPolicy
+	// ExistingID is the ID of the container that uses the name, it is empty if it could not be inspected.
+	ExistingID string
+	// Dockertest is true if the existing container carries the dockertest labels.
+	Dockertest bool
+	Err        error
+}
+
+func (e *NameConflictError) Error() string {
+	owner := "not created by dockertest"
+	if e.Dockertest {
+		owner = "created by dockertest"
+	}
+
+	if e.ExistingID == "" {
+		return fmt.Sprintf("%v: '%s', policy %s: %v", ErrNameConflict, e.Name, e.Policy, e.Err)
+	}
+
+	return fmt.Sprintf("%v: '%s' is used by container %s (%s), policy %s: %v",
+		ErrNameConflict, e.Name, e.ExistingID, owner, e.Policy, e.Err)
+}
+
+func (e *NameConflictError) Unwrap() []error {
+	return []error{ErrNameConflict, e.Err}
+}
+
+// NameConflictResolution tells how Build resolved a name conflict, see Container.NameConflict.
+type NameConflictResolution struct {
+	Policy NameConflictPolicy
+	// RequestedName is the name which was in use.
+	RequestedName string
+	// RemovedID is the ID of the container removed by NameConflictRemove.
+	RemovedID string
+}
+
+// OnNameConflict sets what Build does if a container with the same name exists already,
+// which happens after UseOriginalName or if a previous test run crashed. By default Build fails.
+func (b *ContainerBuilder) OnNameConflict(policy NameConflictPolicy) *ContainerBuilder {
+	b.nameConflictPolicy = policy
+
+	return b
+}
+
+// createContainer creates the container and resolves name conflicts as configured.
+// It returns the ID and name of the created container.
+func (b *ContainerBuilder) createContainer(
+	ctx context.Context,
+	config *container.Config,
+	networkingConfig *dockerNetwork.NetworkingConfig,
+) (string, string, *NameConflictResolution, error) {
+	resp, err := b.create(ctx, config, networkingConfig, b.ContainerName)
+	if err == nil {
+		return resp.ID, b.ContainerName, nil, nil
+	}
+
+	if !errdefs.IsConflict(err) || b.ContainerName == "" {
+		return "", "", nil, createError(b.ContainerConfig.Image, b.ContainerName, err)
+	}
+
+	conflictErr := &NameConflictError{Name: b.ContainerName, Policy: b.nameConflictPolicy, Err: err}
+
+	var existingLabels map[string]string
+
+	existing, inspectErr := b.dockerClient.ContainerInspect(ctx, b.ContainerName)
+	if inspectErr == nil {
+		if existing.Config != nil {
+			existingLabels = existing.Config.Labels
+		}
+
+		conflictErr.ExistingID = existing.ID
+		conflictErr.Dockertest = existingLabels[mainLabel] != ""
+	}
+
+	resolution := &NameConflictResolution{Policy: b.nameConflictPolicy, RequestedName: b.ContainerName}
+
+	switch b.nameConflictPolicy {
+	case NameConflictRemove:
+		if !conflictErr.Dockertest {
+			return "", "", nil, conflictErr
+		}
+
+		if _, reusable := existingLabels[reuseLabel]; reusable {
+			conflictErr.Err = fmt.Errorf("existing container is reusable, it is kept: %w", err)
+
+			return "", "", nil, conflictErr
+		}
+
+		if b.sessionID != "" && existingLabels[sessionLabel] == b.sessionID {
+			conflictErr.Err = fmt.Errorf("existing container belongs to this session, it is kept: %w", err)
+
+			return "", "", nil, conflictErr
+		}
+
+		err := b.dockerClient.ContainerRemove(ctx, existing.ID, types.ContainerRemoveOptions{RemoveVolumes: true, Force: true})
+		if err != nil {
+			conflictErr.Err = fmt.Errorf("error removing existing container: %w", err)
+
+			return "", "", nil, conflictErr
+		}
+
+		resolution.RemovedID = existing.ID
+
+		resp, err = b.create(ctx, config, networkingConfig, b.ContainerName)
+		if err != nil {
+			return "", "", nil, createError(b.ContainerConfig.Image, b.ContainerName, err)
+		}
+
+		b.logger.Warn("removed container with conflicting name",
+			operationAttr("build"), containerAttrs(b.ContainerName, existing.ID))
+
+		return resp.ID, b.ContainerName, resolution, nil
+	case NameConflictUniqueSuffix:
+		name, err := uniqueName(b.ContainerName, func(name string) error {
+			var createErr error
+			resp, createErr = b.create(ctx, config, networkingConfig, name)
+
+			return createErr
+		})
+		if errdefs.IsConflict(err) {
+			conflictErr.Err = err
+
+			return "", "", nil, conflictErr
+		}
+
+		if err != nil {
+			return "", "", nil, createError(b.ContainerConfig.Image, name, err)
+		}
+
+		b.logger.Warn("container name is in use, using a unique name",
+			operationAttr("build"), containerAttrs(name, resp.ID), slog.String("requestedName", b.ContainerName))
+
+		return resp.ID, name, resolution, nil
+	default:
+		return "", "", nil, conflictErr
+	}
+}
+
+// uniqueName calls create with the name suffixed by -2, -3 and so on, until it does not fail with a conflict.
+// It returns the last tried name and the error of create, or the conflict once maxNameSuffix is reached.
+func uniqueName(name string, create func(name string) error) (string, error) {
+	var err error
+
+	for i := 2; i <= maxNameSuffix; i++ {
+		candidate := fmt.Sprintf("%s-%v", name, i)
+
+		err = create(candidate)
+		if !errdefs.IsConflict(err) {
+			return candidate, err
+		}
+	}
+
+	return "", errdefs.Conflict(fmt.Errorf("no unique name up to '%s-%v': %w", name, maxNameSuffix, err))
+}
+
+func (b *ContainerBuilder) create(
+	ctx context.Context,
+	config *container.Config,
+	networkingConfig *dockerNetwork.NetworkingConfig,
+	name string,
+) (container.CreateResponse, error) {
+	return b.dockerClient.ContainerCreate(ctx, config, b.HostConfig, networkingConfig, nil, name)
+}
@@ -0,0 +1,133 @@
+package dockertest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/errdefs"
+)
+
+func TestNameConflictPolicyString(t *testing.T) {
+	tests := []struct {
+		policy NameConflictPolicy
+		want   string
+	}{
+		{policy: NameConflictFail, want: "fail"},
+		{policy: NameConflictRemove, want: "remove"},
+		{policy: NameConflictUniqueSuffix, want: "unique suffix"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.String(); got != tt.want {
+			t.Fatalf("expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestNameConflictError(t *testing.T) {
+	errCreate := errdefs.Conflict(errors.New("name is already in use"))
+
+	tests := []struct {
+		name string
+		err  *NameConflictError
+		want string
+	}{
+		{
+			name: "not inspected",
+			err:  &NameConflictError{Name: "db", Policy: NameConflictFail, Err: errCreate},
+			want: "container name is already in use: 'db', policy fail: name is already in use",
+		},
+		{
+			name: "created by dockertest",
+			err: &NameConflictError{
+				Name: "db", Policy: NameConflictRemove, ExistingID: "abc", Dockertest: true, Err: errCreate,
+			},
+			want: "'db' is used by container abc (created by dockertest), policy remove: name is already in use",
+		},
+		{
+			name: "not created by dockertest",
+			err:  &NameConflictError{Name: "db", Policy: NameConflictRemove, ExistingID: "abc", Err: errCreate},
+			want: "'db' is used by container abc (not created by dockertest), policy remove: name is already in use",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.Error(); !strings.HasSuffix(got, tt.want) {
+				t.Fatalf("expected %q to end with %q", got, tt.want)
+			}
+
+			var err error = fmt.Errorf("build: %w", tt.err)
+
+			if !errors.Is(err, ErrNameConflict) {
+				t.Fatal("expected the error to match ErrNameConflict")
+			}
+
+			if !errors.Is(err, errCreate) {
+				t.Fatal("expected the error to match the create error")
+			}
+
+			var conflictErr *NameConflictError
+			if !errors.As(err, &conflictErr) || conflictErr != tt.err {
+				t.Fatal("expected the error to be a *NameConflictError")
+			}
+		})
+	}
+}
+
+func TestUniqueName(t *testing.T) {
+	errConflict := errdefs.Conflict(errors.New("name is already in use"))
+	errDaemon := errors.New("daemon unavailable")
+
+	tests := []struct {
+		name      string
+		free      string
+		createErr error
+		wantName  string
+		wantErr   error
+		wantCalls int
+	}{
+		{name: "first suffix is free", free: "db-2", wantName: "db-2", wantCalls: 1},
+		{name: "later suffix is free", free: "db-5", wantName: "db-5", wantCalls: 4},
+		{name: "other error", createErr: errDaemon, wantName: "db-2", wantErr: errDaemon, wantCalls: 1},
+		{name: "no free name", wantErr: errConflict, wantCalls: maxNameSuffix - 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+
+			name, err := uniqueName("db", func(name string) error {
+				calls++
+
+				if tt.createErr != nil {
+					return tt.createErr
+				}
+
+				if name == tt.free {
+					return nil
+				}
+
+				return errConflict
+			})
+
+			if tt.wantErr == errConflict && !errdefs.IsConflict(err) {
+				t.Fatalf("expected a conflict, got %v", err)
+			}
+
+			if name != tt.wantName {
+				t.Fatalf("expected name %q, got %q", tt.wantName, name)
+			}
+
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if calls != tt.wantCalls {
+				t.Fatalf("expected %v calls, got %v", tt.wantCalls, calls)
+			}
+		})
+	}
+}
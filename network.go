@@ -1,28 +1,274 @@
 package dockertest
 
 import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	dockerNetwork "github.com/docker/docker/api/types/network"
 )
 
+const driverOptMTU = "com.docker.network.driver.mtu"
+const driverOptBridgeName = "com.docker.network.bridge.name"
+
 // Network represents a docker network.
 type Network struct {
 	NetworkID   string
 	NetworkName string
+	// Subnet is the IPv4 subnet of the network, if it was defined on creation.
+	Subnet string
+	clientEnabled
+}
+
+// Inspect returns the current state of the network as reported by the docker daemon.
+func (n Network) Inspect() (types.NetworkResource, error) {
+	return n.InspectCtx(n.ctx)
+}
+
+// InspectCtx returns the current state of the network using the given context.
+func (n Network) InspectCtx(ctx context.Context) (types.NetworkResource, error) {
+	return n.dockerClient.NetworkInspect(ctx, n.NetworkID, types.NetworkInspectOptions{})
+}
+
+// Remove removes the network, all containers must have been disconnected before.
+func (n Network) Remove() error {
+	return n.RemoveCtx(n.ctx)
+}
+
+// RemoveCtx removes the network using the given context.
+func (n Network) RemoveCtx(ctx context.Context) error {
+	return n.dockerClient.NetworkRemove(ctx, n.NetworkID)
 }
 
 // NetworkBuilder helps with the creation of a docker network.
 type NetworkBuilder struct {
-	Name    string
-	Options types.NetworkCreate
+	Name       string
+	Options    types.NetworkCreate
+	autoSubnet bool
+	reuse      bool
+	subnetPool *subnetPool
 	clientEnabled
 }
 
 // Create creates a new docker network.
 func (n NetworkBuilder) Create() (*Network, error) {
-	resp, err := n.dockerClient.NetworkCreate(n.ctx, n.Name, n.Options)
+	return n.CreateCtx(n.ctx)
+}
+
+// CreateCtx creates a new docker network using the given context.
+func (n NetworkBuilder) CreateCtx(ctx context.Context) (*Network, error) {
+	if n.reuse {
+		network, err := n.reusableNetwork(ctx)
+		if err != nil || network != nil {
+			return network, err
+		}
+
+		n = n.withLabel(reuseLabel, "true")
+	}
+
+	if n.autoSubnet {
+		resp, subnet, err := n.createWithAutoSubnet(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return n.network(resp.ID, subnet), nil
+	}
+
+	resp, err := n.dockerClient.NetworkCreate(ctx, n.Name, n.Options)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Network{resp.ID, n.Name}, nil
+	var subnet string
+	if n.Options.IPAM != nil {
+		subnet = firstIPv4Subnet(n.Options.IPAM.Config)
+	}
+
+	return n.network(resp.ID, subnet), nil
+}
+
+func (n NetworkBuilder) network(networkID, subnet string) *Network {
+	return &Network{
+		NetworkID:     networkID,
+		NetworkName:   n.Name,
+		Subnet:        subnet,
+		clientEnabled: n.clientEnabled,
+	}
+}
+
+// Reuse makes the network reusable across test runs, for containers built with ContainerBuilder.Reuse.
+// Create returns the network of a previous session with the same name instead of creating a new one.
+// Cleanup and CleanupRemains keep reusable networks, they are removed by CleanupForced or PruneReusable.
+func (n NetworkBuilder) Reuse() NetworkBuilder {
+	n.reuse = true
+
+	return n
+}
+
+// reusableNetwork returns the reusable network with the builders name, or nil if there is none.
+func (n NetworkBuilder) reusableNetwork(ctx context.Context) (*Network, error) {
+	filterArgs := filters.NewArgs(
+		filters.Arg("name", n.Name),
+		filters.Arg("label", fmt.Sprintf("%s=%s", mainLabel, n.Options.Labels[mainLabel])),
+		filters.Arg("label", reuseLabel),
+	)
+
+	networks, err := n.dockerClient.NetworkList(ctx, types.NetworkListOptions{Filters: filterArgs})
+	if err != nil {
+		return nil, err
+	}
+
+	// the name filter matches substrings.
+	for _, existing := range networks {
+		if existing.Name == n.Name {
+			return n.network(existing.ID, firstIPv4Subnet(existing.IPAM.Config)), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Internal restricts external access to the network, containers can only talk to each other.
+func (n NetworkBuilder) Internal() NetworkBuilder {
+	n.Options.Internal = true
+
+	return n
+}
+
+// EnableIPv6 enables IPv6 networking using the given IPv6 subnet, for example "fd00:dead:beef::/48".
+func (n NetworkBuilder) EnableIPv6(subnet string) NetworkBuilder {
+	n.Options.EnableIPv6 = true
+	n = n.withIPAMConfig()
+	n.Options.IPAM.Config = append(n.Options.IPAM.Config, dockerNetwork.IPAMConfig{Subnet: subnet})
+
+	return n
+}
+
+// Gateway sets the gateway of the subnet containing the given IP address.
+// If no subnet contains the address, a new IPAM configuration is added with the gateway only.
+func (n NetworkBuilder) Gateway(gateway string) NetworkBuilder {
+	n = n.withIPAMConfig()
+	i := n.ipamConfigIndex(gateway)
+	n.Options.IPAM.Config[i].Gateway = gateway
+
+	return n
+}
+
+// AuxAddress reserves an IP address of the subnet containing it for the given host name,
+// docker will not assign it to any container.
+func (n NetworkBuilder) AuxAddress(hostName, ipAddress string) NetworkBuilder {
+	n = n.withIPAMConfig()
+	i := n.ipamConfigIndex(ipAddress)
+	auxAddresses := map[string]string{hostName: ipAddress}
+
+	for k, v := range n.Options.IPAM.Config[i].AuxAddress {
+		if k != hostName {
+			auxAddresses[k] = v
+		}
+	}
+
+	n.Options.IPAM.Config[i].AuxAddress = auxAddresses
+
+	return n
+}
+
+// Driver sets the network driver, for example "macvlan".
+func (n NetworkBuilder) Driver(driver string) NetworkBuilder {
+	n.Options.Driver = driver
+
+	return n
+}
+
+// DriverOpt sets a driver specific option.
+func (n NetworkBuilder) DriverOpt(key, value string) NetworkBuilder {
+	options := map[string]string{key: value}
+
+	for k, v := range n.Options.Options {
+		if k != key {
+			options[k] = v
+		}
+	}
+
+	n.Options.Options = options
+
+	return n
+}
+
+// MTU sets the maximum transmission unit of the network.
+func (n NetworkBuilder) MTU(mtu int) NetworkBuilder {
+	return n.DriverOpt(driverOptMTU, strconv.Itoa(mtu))
+}
+
+// BridgeName sets the name of the bridge interface created on the docker host.
+func (n NetworkBuilder) BridgeName(name string) NetworkBuilder {
+	return n.DriverOpt(driverOptBridgeName, name)
+}
+
+// Label adds a label to the network, the session labels cannot be overwritten.
+func (n NetworkBuilder) Label(key, value string) NetworkBuilder {
+	if key == mainLabel || key == sessionLabel || key == reuseLabel {
+		return n
+	}
+
+	return n.withLabel(key, value)
+}
+
+// withLabel copies the labels, so modifications do not leak into the builder it was derived from.
+func (n NetworkBuilder) withLabel(key, value string) NetworkBuilder {
+	labels := map[string]string{key: value}
+
+	for k, v := range n.Options.Labels {
+		if k != key {
+			labels[k] = v
+		}
+	}
+
+	n.Options.Labels = labels
+
+	return n
+}
+
+func (n NetworkBuilder) subnet(subnet, ipRange string) NetworkBuilder {
+	n = n.withIPAMConfig()
+	n.Options.IPAM.Config = append(n.Options.IPAM.Config, dockerNetwork.IPAMConfig{
+		Subnet:  subnet,
+		IPRange: ipRange,
+	})
+
+	return n
+}
+
+// withIPAMConfig copies the IPAM configuration, so modifications do not leak into the builder it was derived from.
+func (n NetworkBuilder) withIPAMConfig() NetworkBuilder {
+	ipam := &dockerNetwork.IPAM{Driver: "default"}
+
+	if n.Options.IPAM != nil {
+		ipam.Driver = n.Options.IPAM.Driver
+		ipam.Options = n.Options.IPAM.Options
+		ipam.Config = append(ipam.Config, n.Options.IPAM.Config...)
+	}
+
+	n.Options.IPAM = ipam
+
+	return n
+}
+
+// ipamConfigIndex returns the index of the IPAM configuration whose subnet contains the given IP address.
+func (n NetworkBuilder) ipamConfigIndex(ipAddress string) int {
+	ip := net.ParseIP(ipAddress)
+
+	for i, config := range n.Options.IPAM.Config {
+		_, subnet, err := net.ParseCIDR(config.Subnet)
+		if err == nil && subnet.Contains(ip) {
+			return i
+		}
+	}
+
+	n.Options.IPAM.Config = append(n.Options.IPAM.Config, dockerNetwork.IPAMConfig{})
+
+	return len(n.Options.IPAM.Config) - 1
 }
@@ -0,0 +1,338 @@
+package dockertest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ErrPoolClosed is returned from Acquire after the pool was closed.
+var ErrPoolClosed = errors.New("pool is closed")
+
+// ErrResetFailed is the cause of a failed reset by exec, the container is recreated then.
+var ErrResetFailed = errors.New("reset command failed")
+
+// ErrNotFromPool is returned from Release for containers that were not acquired from the pool.
+var ErrNotFromPool = errors.New("container was not acquired from the pool")
+
+// PoolOptions configures a Pool.
+type PoolOptions struct {
+	// Warm is the number of containers started when the pool is created.
+	Warm int
+	// MaxSize limits the number of containers, Acquire blocks while all of them are in use. Default is Warm, at least 1.
+	MaxSize int
+	// ResetCmd is executed in released containers to reset their state. If it is empty or fails,
+	// the container is removed and a new one is created instead.
+	ResetCmd []string
+}
+
+// Pool hands out isolated, ready containers built from a template, for example to parallel subtests.
+// A container is ready when it met the conditions of the templates ReadyWhen.
+// Pool containers carry the session labels, so they are removed by Session.Cleanup.
+// A Pool is safe for concurrent use.
+type Pool struct {
+	session  *Session
+	template *ContainerBuilder
+	options  PoolOptions
+	mu       sync.Mutex
+	idle     []*Container
+	acquired map[string]bool
+	size     int
+	created  int
+	closed   bool
+	// waiting counts the Acquire calls blocked until a container is released.
+	waiting  int
+	released chan struct{}
+	// done is closed by Close to wake up waiting Acquire calls.
+	done chan struct{}
+}
+
+// NewPool creates a Pool of containers built from the template and starts the warm containers.
+// If a warm container cannot be started, the containers started so far are left to Session.Cleanup.
+func (dt *Session) NewPool(ctx context.Context, template *ContainerBuilder, options PoolOptions) (*Pool, error) {
+	if options.MaxSize < options.Warm {
+		options.MaxSize = options.Warm
+	}
+
+	if options.MaxSize < 1 {
+		options.MaxSize = 1
+	}
+
+	p := &Pool{
+		session:  dt,
+		template: template.NewContainerBuilder(),
+		options:  options,
+		acquired: map[string]bool{},
+		released: make(chan struct{}, options.MaxSize),
+		done:     make(chan struct{}),
+	}
+
+	for i := 0; i < options.Warm; i++ {
+		c, err := p.create(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		p.size++
+		p.idle = append(p.idle, c)
+		p.mu.Unlock()
+	}
+
+	return p, nil
+}
+
+// Acquire returns an idle container, or starts a new one if the pool did not reach its maximum size.
+// Otherwise it blocks until a container is released or the context is done.
+func (p *Pool) Acquire(ctx context.Context) (*Container, error) {
+	for {
+		c, create, err := p.take()
+		if err != nil {
+			return nil, err
+		}
+
+		if c != nil {
+			return c, nil
+		}
+
+		if create {
+			c, err := p.create(ctx)
+			if err != nil {
+				p.shrink()
+
+				return nil, err
+			}
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+
+			if p.closed {
+				removeContainer(ctx, c.containerID, p.session.dockerClient)
+
+				return nil, ErrPoolClosed
+			}
+
+			p.acquired[c.containerID] = true
+
+			return c, nil
+		}
+
+		err = p.wait(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait blocks until a container is released, the pool is closed or the context is done.
+func (p *Pool) wait(ctx context.Context) error {
+	p.mu.Lock()
+	p.waiting++
+	p.mu.Unlock()
+
+	defer func() {
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+	}()
+
+	select {
+	case <-p.released:
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+// Release resets the container and returns it to the pool. The container must not be used afterwards.
+func (p *Pool) Release(ctx context.Context, c *Container) error {
+	p.mu.Lock()
+
+	if !p.acquired[c.containerID] {
+		p.mu.Unlock()
+
+		return fmt.Errorf("%w: '%s'", ErrNotFromPool, c.Name)
+	}
+
+	delete(p.acquired, c.containerID)
+	p.mu.Unlock()
+
+	c, err := p.reset(ctx, c)
+	if err != nil {
+		p.shrink()
+
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		removeContainer(ctx, c.containerID, p.session.dockerClient)
+
+		return nil
+	}
+
+	p.idle = append(p.idle, c)
+	p.signalReleased()
+
+	return nil
+}
+
+// Close removes the idle containers, acquired ones are removed when released.
+// Acquire fails with ErrPoolClosed afterwards, including calls waiting for a container.
+func (p *Pool) Close(ctx context.Context) {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+	}
+
+	p.mu.Unlock()
+
+	for _, c := range idle {
+		removeContainer(ctx, c.containerID, p.session.dockerClient)
+	}
+}
+
+// take pops an idle container or reserves the slot for a new one, if the pool is not full.
+func (p *Pool) take() (*Container, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		return nil, false, ErrPoolClosed
+	}
+
+	if len(p.idle) > 0 {
+		c := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.acquired[c.containerID] = true
+
+		return c, false, nil
+	}
+
+	if p.size < p.options.MaxSize {
+		p.size++
+
+		return nil, true, nil
+	}
+
+	return nil, false, nil
+}
+
+// shrink gives up the slot of a container that could not be created or reset.
+func (p *Pool) shrink() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.size--
+	p.signalReleased()
+}
+
+func (p *Pool) signalReleased() {
+	select {
+	case p.released <- struct{}{}:
+	default:
+	}
+}
+
+// create builds, starts and waits for a new container of the pool.
+func (p *Pool) create(ctx context.Context) (*Container, error) {
+	p.mu.Lock()
+	p.created++
+	b := p.template.NewContainerBuilder()
+
+	if b.originalName != "" {
+		b.Name(fmt.Sprintf("%s-%v", b.originalName, p.created))
+	}
+
+	p.mu.Unlock()
+
+	c, err := b.BuildCtx(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.start(ctx)
+	if err != nil {
+		removeContainer(ctx, c.containerID, p.session.dockerClient)
+
+		return nil, err
+	}
+
+	for _, condition := range c.readiness {
+		err := waitForCondition(ctx, p.session.dockerClient, p.session.logger, c, condition)
+		if err != nil {
+			removeContainer(ctx, c.containerID, p.session.dockerClient)
+
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// reset runs the reset command in the container, if that is not possible the container is replaced by a new one.
+func (p *Pool) reset(ctx context.Context, c *Container) (*Container, error) {
+	if len(p.options.ResetCmd) > 0 {
+		err := execInContainer(ctx, c, p.options.ResetCmd)
+		if err == nil {
+			return c, nil
+		}
+
+		p.session.logger.Warn("could not reset pool container, recreating it",
+			operationAttr("pool reset"), containerAttrs(c.Name, c.containerID), errorAttr(err))
+	}
+
+	removeContainer(ctx, c.containerID, p.session.dockerClient)
+
+	return p.create(ctx)
+}
+
+// execInContainer runs the command in the running container and fails with ErrResetFailed on a non-zero exit code.
+func execInContainer(ctx context.Context, c *Container, cmd []string) error {
+	exec, err := c.dockerClient.ContainerExecCreate(ctx, c.containerID, types.ExecConfig{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	attached, err := c.dockerClient.ContainerExecAttach(ctx, exec.ID, types.ExecStartCheck{})
+	if err != nil {
+		return err
+	}
+
+	defer attached.Close()
+
+	var output bytes.Buffer
+
+	_, err = stdcopy.StdCopy(&output, &output, attached.Reader)
+	if err != nil {
+		return err
+	}
+
+	inspect, err := c.dockerClient.ContainerExecInspect(ctx, exec.ID)
+	if err != nil {
+		return err
+	}
+
+	if inspect.ExitCode != 0 {
+		return fmt.Errorf("%w with exit code %v: %s", ErrResetFailed, inspect.ExitCode, output.String())
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package dockertest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPoolWaitingAcquire(t *testing.T) {
+	tests := []struct {
+		name    string
+		wake    func(p *Pool, cancel context.CancelFunc)
+		wantErr error
+	}{
+		{name: "close", wake: func(p *Pool, _ context.CancelFunc) { p.Close(context.Background()) }, wantErr: ErrPoolClosed},
+		{
+			name: "close twice",
+			wake: func(p *Pool, _ context.CancelFunc) {
+				p.Close(context.Background())
+				p.Close(context.Background())
+			},
+			wantErr: ErrPoolClosed,
+		},
+		{name: "cancel", wake: func(_ *Pool, cancel context.CancelFunc) { cancel() }, wantErr: context.Canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the pool is full, so Acquire waits for a released container.
+			p := &Pool{
+				options:  PoolOptions{MaxSize: 1},
+				size:     1,
+				acquired: map[string]bool{},
+				released: make(chan struct{}, 1),
+				done:     make(chan struct{}),
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			acquired := make(chan error)
+
+			go func() {
+				_, err := p.Acquire(ctx)
+				acquired <- err
+			}()
+
+			waitForWaiters(t, p, 1)
+			tt.wake(p, cancel)
+
+			select {
+			case err := <-acquired:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("Acquire did not return")
+			}
+
+			waitForWaiters(t, p, 0)
+		})
+	}
+}
+
+func TestPoolAcquireClosed(t *testing.T) {
+	p := &Pool{
+		options:  PoolOptions{MaxSize: 1},
+		acquired: map[string]bool{},
+		released: make(chan struct{}, 1),
+		done:     make(chan struct{}),
+	}
+
+	p.Close(context.Background())
+
+	_, err := p.Acquire(context.Background())
+	if !errors.Is(err, ErrPoolClosed) {
+		t.Fatalf("expected ErrPoolClosed, got %v", err)
+	}
+}
+
+// waitForWaiters polls until the number of Acquire calls blocked in the pool is n.
+func waitForWaiters(t *testing.T, p *Pool, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+
+	for {
+		p.mu.Lock()
+		waiting := p.waiting
+		p.mu.Unlock()
+
+		if waiting == n {
+			return
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v waiting Acquire calls, got %v", n, waiting)
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,431 @@
+package dockertest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownReportFormat is returned from WriteTestReport for unsupported formats.
+var ErrUnknownReportFormat = errors.New("unknown report format")
+
+// ReportFormat selects the output of WriteTestReport.
+type ReportFormat int
+
+const (
+	// ReportJUnit writes JUnit XML as understood by most CI systems.
+	ReportJUnit ReportFormat = iota
+	// ReportTest2JSON writes the events of "go tool test2json", one JSON object per line.
+	ReportTest2JSON
+)
+
+// packageFailureTest is the name of the test case which reports a failed package without failed tests,
+// for example because it did not build or TestMain failed.
+const packageFailureTest = "TestMain"
+
+// maxTestOutputLineSize is the longest line ParseTestOutput can handle, -json output contains long lines.
+const maxTestOutputLineSize = 1024 * 1024
+
+// TestStatus is the result of a single test.
+type TestStatus string
+
+// Test results as reported by go test.
+const (
+	TestPassed  TestStatus = "pass"
+	TestFailed  TestStatus = "fail"
+	TestSkipped TestStatus = "skip"
+)
+
+// TestSummary is the result of parsing "go test -v" or "go test -json" output.
+type TestSummary struct {
+	Passed   int
+	Failed   int
+	Skipped  int
+	Duration time.Duration
+	Tests    []TestCase
+}
+
+// TestCase is a single test or subtest of a TestSummary.
+type TestCase struct {
+	Package  string
+	Name     string
+	Status   TestStatus
+	Duration time.Duration
+	Output   string
+}
+
+// testEvent is the event format of "go test -json".
+type testEvent struct {
+	Action  string  `json:"Action"`            //nolint:tagliatelle
+	Package string  `json:"Package"`           //nolint:tagliatelle
+	Test    string  `json:"Test,omitempty"`    //nolint:tagliatelle
+	Elapsed float64 `json:"Elapsed,omitempty"` //nolint:tagliatelle
+	Output  string  `json:"Output,omitempty"`  //nolint:tagliatelle
+}
+
+var (
+	testRunPattern     = regexp.MustCompile(`^=== (RUN|CONT|NAME)\s+(\S+)`)
+	testResultPattern  = regexp.MustCompile(`^\s*--- (PASS|FAIL|SKIP): (\S+) \(([0-9.]+)s\)`)
+	packageResultRegex = regexp.MustCompile(`^(ok|FAIL|\?)\s+(\S+)\s+(?:([0-9.]+)s|\[|\(cached\))`)
+)
+
+// WriteTestReport parses the "go test -v" or "go test -json" output found in the containers log
+// and writes it in the given format.
+func (dt *Session) WriteTestReport(c *Container, format ReportFormat, w io.Writer) (TestSummary, error) {
+	return dt.WriteTestReportCtx(dt.diagnosticsContext(), c, format, w)
+}
+
+// WriteTestReportCtx is like WriteTestReport using the given context.
+func (dt *Session) WriteTestReportCtx(
+	ctx context.Context,
+	c *Container,
+	format ReportFormat,
+	w io.Writer,
+) (TestSummary, error) {
+	log, err := getContainerLog(ctx, dt.dockerClient, c)
+	if err != nil {
+		return TestSummary{}, err
+	}
+
+	summary, err := ParseTestOutput(strings.NewReader(string(log)))
+	if err != nil {
+		return summary, err
+	}
+
+	switch format {
+	case ReportJUnit:
+		return summary, summary.WriteJUnit(w)
+	case ReportTest2JSON:
+		return summary, summary.WriteTest2JSON(w)
+	default:
+		return summary, fmt.Errorf("%w: %v", ErrUnknownReportFormat, format)
+	}
+}
+
+// ParseTestOutput parses "go test -v" or "go test -json" output, the format is detected automatically.
+// Lines that do not belong to the go test output are ignored.
+// A package that failed without a failed test, because it did not build or panicked outside of a test,
+// is reported as a failed test case named TestMain.
+func ParseTestOutput(r io.Reader) (TestSummary, error) {
+	p := &testOutputParser{tests: map[string]*TestCase{}, packages: map[string]*packageResult{}}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxTestOutputLineSize)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		var event testEvent
+		if strings.HasPrefix(line, "{") && json.Unmarshal([]byte(line), &event) == nil && event.Action != "" {
+			p.event(event)
+
+			continue
+		}
+
+		p.line(line)
+	}
+
+	return p.summary(), scanner.Err()
+}
+
+type testOutputParser struct {
+	tests            map[string]*TestCase
+	order            []*TestCase
+	current          *TestCase
+	packages         map[string]*packageResult
+	packageOrder     []string
+	pendingOutput    string
+	packageDurations time.Duration
+}
+
+// packageResult is the result of a package, its output is the output not belonging to a test.
+type packageResult struct {
+	failed bool
+	output string
+}
+
+func (p *testOutputParser) pkg(name string) *packageResult {
+	if r, ok := p.packages[name]; ok {
+		return r
+	}
+
+	r := &packageResult{}
+	p.packages[name] = r
+	p.packageOrder = append(p.packageOrder, name)
+
+	return r
+}
+
+func (p *testOutputParser) test(pkg, name string) *TestCase {
+	key := pkg + "\x00" + name
+	if t, ok := p.tests[key]; ok {
+		return t
+	}
+
+	t := &TestCase{Package: pkg, Name: name}
+	p.tests[key] = t
+	p.order = append(p.order, t)
+
+	return t
+}
+
+func (p *testOutputParser) event(e testEvent) {
+	if e.Test == "" {
+		switch e.Action {
+		case "output":
+			p.pkg(e.Package).output += e.Output
+		case "pass", "fail":
+			p.pkg(e.Package).failed = e.Action == "fail"
+			p.packageDurations += seconds(e.Elapsed)
+		}
+
+		return
+	}
+
+	t := p.test(e.Package, e.Test)
+
+	switch e.Action {
+	case "output":
+		t.Output += e.Output
+	case "pass", "fail", "skip":
+		t.Status = TestStatus(e.Action)
+		t.Duration = seconds(e.Elapsed)
+	}
+}
+
+func (p *testOutputParser) line(line string) {
+	if m := testRunPattern.FindStringSubmatch(line); m != nil {
+		p.current = p.test("", m[2])
+
+		return
+	}
+
+	if m := testResultPattern.FindStringSubmatch(line); m != nil {
+		t := p.test("", m[2])
+		t.Status = TestStatus(strings.ToLower(m[1]))
+		elapsed, _ := strconv.ParseFloat(m[3], 64)
+		t.Duration = seconds(elapsed)
+		p.current = t
+
+		return
+	}
+
+	if m := packageResultRegex.FindStringSubmatch(line); m != nil {
+		p.assignPackage(m[2])
+
+		result := p.pkg(m[2])
+		result.failed = m[1] == "FAIL"
+		result.output += p.pendingOutput
+		p.pendingOutput = ""
+
+		if elapsed, err := strconv.ParseFloat(m[3], 64); err == nil {
+			p.packageDurations += seconds(elapsed)
+		}
+
+		p.current = nil
+
+		return
+	}
+
+	if strings.HasPrefix(line, "=== ") || line == "PASS" || line == "FAIL" {
+		return
+	}
+
+	// output outside of a test belongs to the package, for example build errors or a panic in init.
+	if p.current == nil {
+		p.pendingOutput += line + "\n"
+
+		return
+	}
+
+	p.current.Output += line + "\n"
+}
+
+// assignPackage sets the package of all tests parsed from -v output so far, since go test reports it after the tests.
+func (p *testOutputParser) assignPackage(pkg string) {
+	for key, t := range p.tests {
+		if t.Package == "" {
+			t.Package = pkg
+			delete(p.tests, key)
+			p.tests[pkg+"\x00"+t.Name] = t
+		}
+	}
+}
+
+func (p *testOutputParser) summary() TestSummary {
+	s := TestSummary{Duration: p.packageDurations}
+	failedPackages := map[string]bool{}
+
+	for _, t := range p.order {
+		switch t.Status {
+		case TestPassed:
+			s.Passed++
+		case TestFailed:
+			s.Failed++
+		case TestSkipped:
+			s.Skipped++
+		default:
+			// a test without result did not finish, for example because the test binary panicked.
+			t.Status = TestFailed
+			s.Failed++
+		}
+
+		if t.Status == TestFailed {
+			failedPackages[t.Package] = true
+		}
+
+		if p.packageDurations == 0 && !strings.Contains(t.Name, "/") {
+			s.Duration += t.Duration
+		}
+
+		s.Tests = append(s.Tests, *t)
+	}
+
+	for _, name := range p.packageOrder {
+		result := p.packages[name]
+		if !result.failed || failedPackages[name] {
+			continue
+		}
+
+		s.Failed++
+		s.Tests = append(s.Tests, TestCase{Package: name, Name: packageFailureTest, Status: TestFailed, Output: result.output})
+	}
+
+	return s
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message  string `xml:"message,attr"`
+	Contents string `xml:",chardata"`
+}
+
+// WriteJUnit writes the summary as JUnit XML with one test suite per package.
+func (s TestSummary) WriteJUnit(w io.Writer) error {
+	suites := map[string]*junitTestSuite{}
+	report := junitTestSuites{Time: formatJUnitTime(s.Duration)}
+
+	for _, t := range s.Tests {
+		suite, ok := suites[t.Package]
+		if !ok {
+			suite = &junitTestSuite{Name: t.Package}
+			suites[t.Package] = suite
+		}
+
+		testCase := junitTestCase{ClassName: t.Package, Name: t.Name, Time: formatJUnitTime(t.Duration)}
+
+		switch t.Status {
+		case TestFailed:
+			testCase.Failure = &junitMessage{Message: "Failed", Contents: t.Output}
+			suite.Failures++
+		case TestSkipped:
+			testCase.Skipped = &junitMessage{Message: "Skipped", Contents: t.Output}
+			suite.Skipped++
+		default:
+			testCase.SystemOut = t.Output
+		}
+
+		suite.Tests++
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	for _, name := range sortedKeys(suites) {
+		suite := suites[name]
+		suite.Time = formatJUnitTime(suiteDuration(suite, s.Tests))
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+		report.Suites = append(report.Suites, *suite)
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	return encoder.Encode(report)
+}
+
+// WriteTest2JSON writes the summary as events in the format of "go test -json".
+func (s TestSummary) WriteTest2JSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+
+	for _, t := range s.Tests {
+		events := []testEvent{{Action: "run", Package: t.Package, Test: t.Name}}
+
+		if t.Output != "" {
+			events = append(events, testEvent{Action: "output", Package: t.Package, Test: t.Name, Output: t.Output})
+		}
+
+		events = append(events, testEvent{
+			Action:  string(t.Status),
+			Package: t.Package,
+			Test:    t.Name,
+			Elapsed: t.Duration.Seconds(),
+		})
+
+		for _, e := range events {
+			if err := encoder.Encode(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func suiteDuration(suite *junitTestSuite, tests []TestCase) time.Duration {
+	var d time.Duration
+
+	for _, t := range tests {
+		if t.Package == suite.Name && !strings.Contains(t.Name, "/") {
+			d += t.Duration
+		}
+	}
+
+	return d
+}
+
+func formatJUnitTime(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
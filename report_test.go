@@ -0,0 +1,186 @@
+package dockertest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseTestOutput(t *testing.T) {
+	tests := []struct {
+		name          string
+		output        string
+		passed        int
+		failed        int
+		skipped       int
+		failedTests   []string
+		failureOutput string
+		// unwantedOutput must not be credited to any test, unwantedFailureOutput not to the failed ones.
+		unwantedOutput        string
+		unwantedFailureOutput string
+	}{
+		{
+			name: "verbose",
+			output: `=== RUN   TestA
+--- PASS: TestA (0.00s)
+=== RUN   TestB
+    b_test.go:10: boom
+--- FAIL: TestB (0.01s)
+=== RUN   TestC
+    c_test.go:5: not today
+--- SKIP: TestC (0.00s)
+FAIL
+FAIL	example.com/a	0.012s
+`,
+			passed:        1,
+			failed:        1,
+			skipped:       1,
+			failedTests:   []string{"example.com/a.TestB"},
+			failureOutput: "b_test.go:10: boom",
+		},
+		{
+			name: "build failure",
+			output: `# example.com/b [example.com/b.test]
+./b_test.go:5:2: undefined: foo
+FAIL	example.com/b [build failed]
+ok  	example.com/c	0.004s
+`,
+			failed:        1,
+			failedTests:   []string{"example.com/b.TestMain"},
+			failureOutput: "undefined: foo",
+		},
+		{
+			name: "panic in init",
+			output: `panic: init failed
+
+goroutine 1 [running]:
+example.com/c.init.0()
+FAIL	example.com/c	0.003s
+`,
+			failed:        1,
+			failedTests:   []string{"example.com/c.TestMain"},
+			failureOutput: "panic: init failed",
+		},
+		{
+			name: "TestMain fails after passed tests",
+			output: `=== RUN   TestA
+--- PASS: TestA (0.00s)
+PASS
+FAIL	example.com/d	0.003s
+`,
+			passed:      1,
+			failed:      1,
+			failedTests: []string{"example.com/d.TestMain"},
+		},
+		{
+			name: "timeout",
+			output: `=== RUN   TestSlow
+panic: test timed out after 1s
+running tests:
+	TestSlow (1s)
+FAIL	example.com/e	1.005s
+`,
+			failed:        1,
+			failedTests:   []string{"example.com/e.TestSlow"},
+			failureOutput: "test timed out",
+		},
+		{
+			name: "parallel tests",
+			output: `=== RUN   TestA
+=== PAUSE TestA
+=== RUN   TestB
+=== PAUSE TestB
+=== CONT  TestA
+=== CONT  TestB
+=== NAME  TestA
+    a_test.go:10: boom
+=== NAME  TestB
+    b_test.go:12: fine
+--- FAIL: TestA (0.01s)
+--- PASS: TestB (0.01s)
+FAIL
+FAIL	example.com/h	0.015s
+`,
+			passed:                1,
+			failed:                1,
+			failedTests:           []string{"example.com/h.TestA"},
+			failureOutput:         "a_test.go:10: boom",
+			unwantedOutput:        "=== NAME",
+			unwantedFailureOutput: "b_test.go:12: fine",
+		},
+		{
+			name: "cached package",
+			output: `=== RUN   TestA
+--- PASS: TestA (0.00s)
+PASS
+ok  	example.com/i	(cached)
+`,
+			passed: 1,
+		},
+		{
+			name: "json",
+			output: `{"Action":"run","Package":"example.com/f","Test":"TestA"}
+{"Action":"output","Package":"example.com/f","Test":"TestA","Output":"=== RUN   TestA\n"}
+{"Action":"pass","Package":"example.com/f","Test":"TestA","Elapsed":0.01}
+{"Action":"pass","Package":"example.com/f","Elapsed":0.02}
+`,
+			passed: 1,
+		},
+		{
+			name: "json package failure",
+			output: `{"Action":"start","Package":"example.com/g"}
+{"Action":"output","Package":"example.com/g","Output":"setup failed: database unavailable\n"}
+{"Action":"output","Package":"example.com/g","Output":"FAIL\texample.com/g\t0.002s\n"}
+{"Action":"fail","Package":"example.com/g","Elapsed":0.002}
+`,
+			failed:        1,
+			failedTests:   []string{"example.com/g.TestMain"},
+			failureOutput: "setup failed: database unavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := ParseTestOutput(strings.NewReader(tt.output))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if summary.Passed != tt.passed || summary.Failed != tt.failed || summary.Skipped != tt.skipped {
+				t.Fatalf("expected %v passed, %v failed, %v skipped, got %v, %v, %v",
+					tt.passed, tt.failed, tt.skipped, summary.Passed, summary.Failed, summary.Skipped)
+			}
+
+			var failedTests []string
+
+			for _, test := range summary.Tests {
+				if test.Status != TestFailed {
+					continue
+				}
+
+				failedTests = append(failedTests, test.Package+"."+test.Name)
+
+				if !strings.Contains(test.Output, tt.failureOutput) {
+					t.Fatalf("expected output of %s to contain '%s', got '%s'", test.Name, tt.failureOutput, test.Output)
+				}
+
+				if tt.unwantedFailureOutput != "" && strings.Contains(test.Output, tt.unwantedFailureOutput) {
+					t.Fatalf("expected output of %s not to contain '%s'", test.Name, tt.unwantedFailureOutput)
+				}
+			}
+
+			if strings.Join(failedTests, ",") != strings.Join(tt.failedTests, ",") {
+				t.Fatalf("expected failed tests %v, got %v", tt.failedTests, failedTests)
+			}
+
+			for _, test := range summary.Tests {
+				if test.Package == "" {
+					t.Fatalf("expected a package for %s", test.Name)
+				}
+
+				if tt.unwantedOutput != "" && strings.Contains(test.Output, tt.unwantedOutput) {
+					t.Fatalf("expected output of %s not to contain '%s'", test.Name, tt.unwantedOutput)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package dockertest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	dockerNetwork "github.com/docker/docker/api/types/network"
+)
+
+// reuseLabel marks reusable containers, its value is the hash of the containers configuration.
+const reuseLabel = mainLabel + "-reuse"
+
+// Reuse makes the container reusable across test runs. Build returns a running container of a previous
+// session, if it was built with the same configuration, instead of creating a new one.
+// The configuration is identified by a hash of ContainerConfig, HostConfig and NetworkingConfig,
+// which leaves out the session label, so it is stable across sessions.
+// Networks are identified by their name, so the hash does not change with the network IDs of a session.
+// Cleanup and CleanupRemains keep reusable containers, they are removed by CleanupForced or PruneReusable.
+// Networks a reusable container is connected to must also outlive the session, see NetworkBuilder.Reuse.
+func (b *ContainerBuilder) Reuse() *ContainerBuilder {
+	b.reuse = true
+
+	return b
+}
+
+// PruneReusable stops and removes all reusable containers and networks labelled like this session.
+func (dt *Session) PruneReusable(ctx context.Context) {
+	filterArgs := filters.NewArgs(
+		filters.Arg("label", fmt.Sprintf("%s=%s", mainLabel, dt.mainLabel)),
+		filters.Arg("label", reuseLabel),
+	)
+
+	stopContainers(ctx, filterArgs, dt.dockerClient, stopTimeout(ctx), false, dt.logger)
+	removeContainers(ctx, filterArgs, dt.dockerClient, false, dt.logger)
+	removeNetworks(ctx, filterArgs, dt.dockerClient, false, dt.logger)
+}
+
+// reusableContainer returns a running container built with the same configuration as the builder.
+// If there is none, it returns the configuration hash the container to build must be labelled with.
+// Containers with the same configuration which are not running are removed, so they cannot be found again.
+func (b *ContainerBuilder) reusableContainer(ctx context.Context) (*Container, string, error) {
+	hash, err := b.configHash()
+	if err != nil {
+		return nil, "", err
+	}
+
+	filterArgs := filters.NewArgs(
+		filters.Arg("label", fmt.Sprintf("%s=%s", mainLabel, b.ContainerConfig.Labels[mainLabel])),
+		filters.Arg("label", fmt.Sprintf("%s=%s", reuseLabel, hash)),
+	)
+
+	containers, err := listContainers(ctx, filterArgs, b.dockerClient, false)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, existing := range containers {
+		if existing.State == "running" {
+			return b.container(existing.ID, strings.TrimPrefix(existing.Names[0], "/")), hash, nil
+		}
+
+		removeContainer(ctx, existing.ID, b.dockerClient)
+	}
+
+	return nil, hash, nil
+}
+
+// configHash returns a hash of the builders configuration, which is stable across sessions.
+func (b *ContainerBuilder) configHash() (string, error) {
+	config := *b.ContainerConfig
+	config.Labels = map[string]string{}
+
+	for k, v := range b.ContainerConfig.Labels {
+		if k != sessionLabel && k != reuseLabel {
+			config.Labels[k] = v
+		}
+	}
+
+	// encoding/json sorts map keys, so the encoding is stable.
+	data, err := json.Marshal(struct {
+		Config           *container.Config
+		HostConfig       *container.HostConfig
+		NetworkingConfig *dockerNetwork.NetworkingConfig
+	}{&config, b.HostConfig, networkingConfigByName(b.NetworkingConfig)})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// networkingConfigByName returns a copy of the networking config without the network IDs,
+// the endpoints stay identified by network name.
+func networkingConfigByName(networkingConfig *dockerNetwork.NetworkingConfig) *dockerNetwork.NetworkingConfig {
+	if networkingConfig == nil {
+		return nil
+	}
+
+	byName := &dockerNetwork.NetworkingConfig{EndpointsConfig: map[string]*dockerNetwork.EndpointSettings{}}
+
+	for networkName, endpoint := range networkingConfig.EndpointsConfig {
+		if endpoint == nil {
+			byName.EndpointsConfig[networkName] = nil
+
+			continue
+		}
+
+		e := *endpoint
+		e.NetworkID = ""
+		byName.EndpointsConfig[networkName] = &e
+	}
+
+	return byName
+}
@@ -0,0 +1,47 @@
+package dockertest
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	dockerNetwork "github.com/docker/docker/api/types/network"
+)
+
+func TestConfigHash(t *testing.T) {
+	builder := func(sessionID, networkID, alias string) *ContainerBuilder {
+		b := &ContainerBuilder{
+			ContainerConfig: &container.Config{
+				Image:  "redis",
+				Labels: map[string]string{mainLabel: defaultMainLabelValue, sessionLabel: sessionID},
+			},
+			HostConfig:       &container.HostConfig{},
+			NetworkingConfig: &dockerNetwork.NetworkingConfig{},
+		}
+
+		return b.Connect(&Network{NetworkID: networkID, NetworkName: "backend"}).NetworkAliases(
+			&Network{NetworkID: networkID, NetworkName: "backend"}, alias)
+	}
+
+	hash := func(b *ContainerBuilder) string {
+		h, err := b.configHash()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return h
+	}
+
+	first := builder("session-1", "network-1", "cache")
+
+	if hash(first) != hash(builder("session-2", "network-2", "cache")) {
+		t.Fatal("expected the hash to be stable across sessions and network IDs")
+	}
+
+	if hash(first) == hash(builder("session-1", "network-1", "store")) {
+		t.Fatal("expected the hash to change with the network aliases")
+	}
+
+	if first.NetworkingConfig.EndpointsConfig["backend"].NetworkID != "network-1" {
+		t.Fatal("expected the builders network ID to be kept")
+	}
+}
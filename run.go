@@ -0,0 +1,138 @@
+package dockertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// RunResult is the outcome of a container run to completion by Session.Run.
+type RunResult struct {
+	ExitCode  int
+	Stdout    []byte
+	Stderr    []byte
+	Duration  time.Duration
+	OOMKilled bool
+}
+
+// ExitError is returned from Session.Run if the container exited with a non-zero exit code.
+type ExitError struct {
+	Container string
+	ExitCode  int
+	OOMKilled bool
+}
+
+func (e *ExitError) Error() string {
+	if e.OOMKilled {
+		return fmt.Sprintf("container '%s' was OOM killed, exit code %v", e.Container, e.ExitCode)
+	}
+
+	return fmt.Sprintf("container '%s' exited with exit code %v", e.Container, e.ExitCode)
+}
+
+// Run creates a container from the builder, starts it and waits for it to exit.
+// While running, the containers stdout and stderr are streamed to the given output writers.
+// A non-zero exit code is returned as *ExitError along with the RunResult.
+// If the context is done before the container exits, the container is killed.
+func (dt *Session) Run(ctx context.Context, b *ContainerBuilder, output ...io.Writer) (RunResult, error) {
+	c, err := b.BuildCtx(ctx)
+	if err != nil {
+		return RunResult{ExitCode: -1}, err
+	}
+
+	return dt.runContainer(ctx, c, output)
+}
+
+func (dt *Session) runContainer(ctx context.Context, c *Container, output []io.Writer) (RunResult, error) {
+	waitCh, errCh := dt.dockerClient.ContainerWait(ctx, c.containerID, container.WaitConditionNextExit)
+	startedAt := time.Now()
+
+	err := c.start(ctx)
+	if err != nil {
+		return RunResult{ExitCode: -1}, err
+	}
+
+	var (
+		stdout, stderr bytes.Buffer
+		logsDone       = dt.followRunLogs(ctx, c, &stdout, &stderr, output)
+		result         = RunResult{ExitCode: -1}
+	)
+
+	select {
+	case res := <-waitCh:
+		result.ExitCode = int(res.StatusCode)
+	case err := <-errCh:
+		dt.killContainer(c)
+
+		return result, err
+	}
+
+	result.Duration = time.Since(startedAt)
+
+	<-logsDone
+
+	result.Stdout = stdout.Bytes()
+	result.Stderr = stderr.Bytes()
+
+	inspectResult, err := dt.dockerClient.ContainerInspect(ctx, c.containerID)
+	if err == nil && inspectResult.State != nil {
+		result.OOMKilled = inspectResult.State.OOMKilled
+	}
+
+	if result.ExitCode != 0 {
+		return result, &ExitError{Container: c.Name, ExitCode: result.ExitCode, OOMKilled: result.OOMKilled}
+	}
+
+	return result, nil
+}
+
+// followRunLogs copies the containers output until it exits, the returned channel is closed when done.
+func (dt *Session) followRunLogs(ctx context.Context, c *Container, stdout, stderr io.Writer, output []io.Writer) chan struct{} {
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		logReader, err := dt.dockerClient.ContainerLogs(ctx, c.containerID, types.ContainerLogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Follow:     true,
+		})
+		if err != nil {
+			dt.logger.Error("could not follow container log",
+				operationAttr("run"), containerAttrs(c.Name, c.containerID), errorAttr(err))
+
+			return
+		}
+
+		defer func() {
+			_ = logReader.Close()
+		}()
+
+		_, err = stdcopy.StdCopy(
+			io.MultiWriter(append([]io.Writer{stdout}, output...)...),
+			io.MultiWriter(append([]io.Writer{stderr}, output...)...),
+			logReader,
+		)
+		if err != nil {
+			dt.logger.Error("could not follow container log",
+				operationAttr("run"), containerAttrs(c.Name, c.containerID), errorAttr(err))
+		}
+	}()
+
+	return done
+}
+
+func (dt *Session) killContainer(c *Container) {
+	err := dt.dockerClient.ContainerKill(context.Background(), c.containerID, "kill")
+	if err != nil {
+		dt.logger.Error("could not kill container",
+			operationAttr("run"), containerAttrs(c.Name, c.containerID), errorAttr(err))
+	}
+}
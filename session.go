@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/docker/docker/api/types"
@@ -25,8 +27,9 @@ const sessionLabel = mainLabel + "-session"
 const defaultMainLabelValue = "dockertest"
 
 // NewSession creates a new Test and returns a Session instance to work with.
-func NewSession() (*Session, error) {
-	sessionID := time.Now().Format("20060102150405")
+func NewSession(opts ...SessionOption) (*Session, error) {
+	createdAt := time.Now()
+	sessionID := createdAt.Format(sessionIDLayout)
 
 	dockerClient, err := client.NewClientWithOpts(client.FromEnv)
 	if err != nil {
@@ -35,22 +38,43 @@ func NewSession() (*Session, error) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	return &Session{
-		ID:        sessionID,
-		mainLabel: defaultMainLabelValue,
+	dt := &Session{
+		ID:         sessionID,
+		createdAt:  createdAt,
+		mainLabel:  defaultMainLabelValue,
+		subnetPool: defaultSubnetPoolOrPanic(),
+		started:    map[string]bool{},
 		clientEnabled: clientEnabled{
 			cancelCtx:    cancel,
 			ctx:          ctx,
 			dockerClient: dockerClient,
+			logger:       newDefaultLogger(),
 		},
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(dt)
+	}
+
+	dt.logger = dt.logger.With(slog.String("session", sessionID))
+
+	return dt, nil
 }
 
 // Session is the main object when starting a docker driven container test.
 type Session struct {
-	ID        string
-	logDir    string
-	mainLabel string
+	ID            string
+	logDir        string
+	createdAt     time.Time
+	mainLabel     string
+	chaos         *Chaos
+	subnetPool    *subnetPool
+	mu            sync.Mutex
+	containers    []*Container
+	started       map[string]bool
+	console       *console
+	logConsumers  []LogConsumer
+	statsSamplers []*StatsSampler
 	clientEnabled
 }
 
@@ -77,82 +101,145 @@ func (dt *Session) SetLabel(label string) {
 	dt.mainLabel = label
 }
 
-// NotifyContainerExit returns a channel that blocks until the container has exited.
-// If the operation times out, it will try to kill the container.
+// NotifyContainerExit returns a channel that receives true once, when the container has exited.
+// If the operation times out, the container is killed. Use NotifyContainerExitResult to receive the reason.
 func (dt *Session) NotifyContainerExit(container *Container, timeout time.Duration) chan bool {
-	exitedCh := make(chan bool)
+	return dt.NotifyContainerExitCtx(dt.ctx, container, timeout)
+}
 
-	go func() {
-		ctxTimeout, cancel := context.WithTimeout(dt.ctx, timeout)
-		defer cancel()
-		defer close(exitedCh)
+// NotifyContainerExitCtx is like NotifyContainerExit, the wait ends at the timeout or when the context is done.
+func (dt *Session) NotifyContainerExitCtx(ctx context.Context, container *Container, timeout time.Duration) chan bool {
+	exited := make(chan bool, 1)
+	result := dt.NotifyContainerExitResultCtx(ctx, container, timeout)
 
-		if !waitForContainer(ctxTimeout, containerHasFadeAway, dt.dockerClient, container.containerID) {
-			err := dt.dockerClient.ContainerKill(context.Background(), container.containerID, "kill")
-			if err != nil {
-				fmt.Println("Error while killing container,", err)
-			}
+	go func() {
+		if err := <-result; err != nil {
+			dt.logger.Warn("container did not exit as expected",
+				operationAttr("notify exit"), containerAttrs(container.Name, container.containerID), errorAttr(err))
 		}
-		exitedCh <- true
+
+		exited <- true
+		close(exited)
 	}()
 
-	return exitedCh
+	return exited
 }
 
-// NotifyContainerHealthy returns a channel that blocks until the given
-// container reaches healthy state or timeout occurrs.
-func (dt *Session) NotifyContainerHealthy(container *Container, timeout time.Duration) chan error {
-	healthErr := make(chan error)
+// NotifyContainerExitResult returns a channel that receives the result of Container.WaitExit once.
+// If the operation times out, the container is killed and ErrKilledAfterTimeout is received.
+func (dt *Session) NotifyContainerExitResult(container *Container, timeout time.Duration) chan error {
+	return dt.NotifyContainerExitResultCtx(dt.ctx, container, timeout)
+}
 
-	go func() {
-		ctxTimeout, cancel := context.WithTimeout(dt.ctx, timeout)
-		defer cancel()
-		defer close(healthErr)
+// NotifyContainerExitResultCtx is like NotifyContainerExitResult, the wait ends at the timeout
+// or when the context is done.
+func (dt *Session) NotifyContainerExitResultCtx(
+	ctx context.Context,
+	container *Container,
+	timeout time.Duration,
+) chan error {
+	return notify(ctx, timeout, container.WaitExit)
+}
 
-		if !waitForContainer(ctxTimeout, containerIsHealthy, dt.dockerClient, container.containerID) {
-			healthErr <- fmt.Errorf("%w. timed out after %s", ErrContainerStartTimeout, timeout)
-		}
-		healthErr <- nil
-	}()
+// NotifyContainerHealthy returns a channel that receives the result of Container.WaitHealthy once.
+func (dt *Session) NotifyContainerHealthy(container *Container, timeout time.Duration) chan error {
+	return dt.NotifyContainerHealthyCtx(dt.ctx, container, timeout)
+}
 
-	return healthErr
+// NotifyContainerHealthyCtx is like NotifyContainerHealthy, the wait ends at the timeout or when the context is done.
+func (dt *Session) NotifyContainerHealthyCtx(ctx context.Context, container *Container, timeout time.Duration) chan error {
+	return notify(ctx, timeout, container.WaitHealthy)
 }
 
-// NotifyContainerLogContains returns a channel that blocks until the given
-// search string was found in the containers log output.
+// NotifyContainerLogContains returns a channel that receives the result of Container.WaitLog once.
 func (dt *Session) NotifyContainerLogContains(container *Container, timeout time.Duration, search string) chan error {
-	logContainsErr := make(chan error)
+	return dt.NotifyContainerLogContainsCtx(dt.ctx, container, timeout, search)
+}
+
+// NotifyContainerLogContainsCtx is like NotifyContainerLogContains, the wait ends at the timeout or when the context is done.
+func (dt *Session) NotifyContainerLogContainsCtx(
+	ctx context.Context,
+	container *Container,
+	timeout time.Duration,
+	search string,
+) chan error {
+	return notify(ctx, timeout, func(ctx context.Context) error {
+		return container.WaitLog(ctx, search)
+	})
+}
+
+// notify runs the wait in the background. The returned channel is buffered, so the result is sent exactly once
+// without blocking, even if it is never received, and is closed afterwards.
+func notify(ctx context.Context, timeout time.Duration, wait func(ctx context.Context) error) chan error {
+	result := make(chan error, 1)
 
 	go func() {
-		ctxTimeout, cancel := context.WithTimeout(dt.ctx, timeout)
+		ctxTimeout, cancel := context.WithTimeout(ctx, timeout)
 		defer cancel()
-		defer close(logContainsErr)
 
-		if err := waitForContainerLog(ctxTimeout, search, dt.dockerClient, container.containerID); err != nil {
-			logContainsErr <- fmt.Errorf("error parsing log: %w", err)
-		}
-		logContainsErr <- nil
+		result <- wait(ctxTimeout)
+		close(result)
 	}()
 
-	return logContainsErr
+	return result
 }
 
 // Cleanup removes all resources (like containers/networks) used for this session.
 func (dt *Session) Cleanup() {
-	ctx, cancel := context.WithTimeout(context.Background(), cleanerTimeout)
+	dt.CleanupWithTimeout(cleanerTimeout)
+}
+
+// CleanupWithTimeout is like Cleanup, but limits the time for the cleanup to the given timeout instead of 10 seconds.
+func (dt *Session) CleanupWithTimeout(timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	dt.CleanupCtx(ctx)
+}
+
+// CleanupCtx is like Cleanup, but uses the given context. If it has a deadline, running containers
+// are given the time until the deadline to stop, otherwise 10 seconds.
+// The context should not be the sessions context, since that may already be cancelled.
+func (dt *Session) CleanupCtx(ctx context.Context) {
+	dt.cleanup(ctx, false)
+}
+
+// CleanupForced is like CleanupCtx, but also removes the reusable containers of the session, see ContainerBuilder.Reuse.
+func (dt *Session) CleanupForced(ctx context.Context) {
+	dt.cleanup(ctx, true)
+}
+
+func (dt *Session) cleanup(ctx context.Context, force bool) {
+	if dt.chaos != nil {
+		err := dt.chaos.Restore(ctx)
+		if err != nil {
+			dt.logger.Error("could not restore chaos effects", operationAttr("cleanup"), errorAttr(err))
+		}
+	}
+
 	cleaner := newCleaner(ctx, dt)
+	cleaner.force = force
 	cleaner.stopSessionContainers(dt.ID)
 	cleaner.removeDockerTestContainers(dt.ID)
+	cleaner.removeSessionVolumes(dt.ID)
+	cleaner.removeSessionImages(dt.ID)
 	cleaner.cleanupTestNetwork()
 }
 
-// CleanupRemains removes all resources (like containers/networks) this kind of test - identified by the Session Label.
+// CleanupRemains removes all resources (like containers/networks) this kind of test - identified by the Session Label,
+// see SetLabel.
+// Reusable containers are kept, they are removed by PruneReusable.
 func (dt *Session) CleanupRemains() {
-	c := newRemainsCleaner(dt.ctx, dt.dockerClient)
+	dt.CleanupRemainsCtx(dt.diagnosticsContext())
+}
+
+// CleanupRemainsCtx is like CleanupRemains, but uses the given context.
+func (dt *Session) CleanupRemainsCtx(ctx context.Context) {
+	c := newRemainsCleaner(ctx, dt.dockerClient, dt.mainLabel, dt.logger)
 	c.stopContainers()
 	c.removeDockerTestContainers()
+	c.removeVolumes()
+	c.removeImages()
 	c.cleanupTestNetwork()
 }
 
@@ -166,10 +253,15 @@ func (dt *Session) getLabels() map[string]string {
 // StartContainer starts one or multiple given containers.
 // If some containers return error while starting the last error will be returned.
 func (dt *Session) StartContainer(container ...*Container) error {
+	return dt.StartContainerCtx(dt.ctx, container...)
+}
+
+// StartContainerCtx starts one or multiple given containers using the given context.
+func (dt *Session) StartContainerCtx(ctx context.Context, container ...*Container) error {
 	var err error
 
 	for i := range container {
-		errStart := container[i].Start()
+		errStart := container[i].StartCtx(ctx)
 		if errStart != nil {
 			err = errStart
 		}
@@ -178,38 +270,65 @@ func (dt *Session) StartContainer(container ...*Container) error {
 	return err
 }
 
+// diagnosticsContext returns a context for dumps and cleanups, which must work after the session context
+// was cancelled, for example by a LogWatchdog.
+func (dt *Session) diagnosticsContext() context.Context {
+	return context.WithoutCancel(dt.ctx)
+}
+
 // DumpInspect dumps an json file with the content of "docker inspect" into the log directory.
 func (dt *Session) DumpInspect(container ...*Container) {
+	dt.DumpInspectCtx(dt.diagnosticsContext(), container...)
+}
+
+// DumpInspectCtx dumps the inspect results using the given context.
+func (dt *Session) DumpInspectCtx(ctx context.Context, container ...*Container) {
 	for _, c := range container {
-		dumpInspectContainter(dt.ctx, dt.dockerClient, c, dt.logDir)
+		dumpInspectContainter(ctx, dt.dockerClient, c, dt.logDir, dt.logger)
 	}
 }
 
 // DumpContainerLogsToDir dumps the log of one or multiple containers to the log directory.
 func (dt *Session) DumpContainerLogsToDir(container ...*Container) {
+	dt.DumpContainerLogsToDirCtx(dt.diagnosticsContext(), container...)
+}
+
+// DumpContainerLogsToDirCtx dumps the container logs using the given context.
+func (dt *Session) DumpContainerLogsToDirCtx(ctx context.Context, container ...*Container) {
 	for _, c := range container {
-		dumpContainerLog(dt.ctx, dt.dockerClient, c, dt.logDir)
+		dumpContainerLog(ctx, dt.dockerClient, c, dt.logDir, dt.logger)
 	}
 }
 
 // DumpContainerHealthCheckLogsToDir dumps the healthCheck logs of one or multiple containers to the log directory.
 func (dt *Session) DumpContainerHealthCheckLogsToDir(container ...*Container) {
+	dt.DumpContainerHealthCheckLogsToDirCtx(dt.diagnosticsContext(), container...)
+}
+
+// DumpContainerHealthCheckLogsToDirCtx dumps the health check logs using the given context.
+func (dt *Session) DumpContainerHealthCheckLogsToDirCtx(ctx context.Context, container ...*Container) {
 	for _, c := range container {
-		dumpContainerHealthCheckLog(dt.ctx, dt.dockerClient, c, dt.logDir)
+		dumpContainerHealthCheckLog(ctx, dt.dockerClient, c, dt.logDir, dt.logger)
 	}
 }
 
 // WriteContainerLogs writes the log of the given containers.
 func (dt *Session) WriteContainerLogs(w io.Writer, container ...*Container) {
+	dt.WriteContainerLogsCtx(dt.diagnosticsContext(), w, container...)
+}
+
+// WriteContainerLogsCtx writes the log of the given containers using the given context.
+func (dt *Session) WriteContainerLogsCtx(ctx context.Context, w io.Writer, container ...*Container) {
 	for _, c := range container {
-		log, err := getContainerLog(dt.ctx, dt.dockerClient, c)
+		log, err := getContainerLog(ctx, dt.dockerClient, c)
 		if err != nil {
-			fmt.Printf("error writing container '%s' log: %v", c.Name, err)
+			dt.logger.Error("could not read container log",
+				operationAttr("write logs"), containerAttrs(c.Name, c.containerID), errorAttr(err))
 
 			continue
 		}
 
-		writeLog(w, c, log)
+		writeLog(w, c, log, dt.logger)
 	}
 }
 
@@ -218,23 +337,15 @@ func (dt *Session) CreateBasicNetwork(networkName string) NetworkBuilder {
 	ctx, cancel := context.WithTimeout(context.Background(), cleanerTimeout)
 	defer cancel()
 
+	return dt.CreateBasicNetworkCtx(ctx, networkName)
+}
+
+// CreateBasicNetworkCtx is like CreateBasicNetwork, the given context is used to remove previous test networks.
+func (dt *Session) CreateBasicNetworkCtx(ctx context.Context, networkName string) NetworkBuilder {
 	cleaner := newCleaner(ctx, dt)
 	cleaner.cleanupTestNetwork()
 
-	return NetworkBuilder{
-		clientEnabled: dt.clientEnabled,
-		Name:          networkName,
-		Options: types.NetworkCreate{
-			CheckDuplicate: true,
-			Attachable:     true,
-			Driver:         "bridge",
-			IPAM: &dockerNetwork.IPAM{
-				Driver: "default",
-				Config: []dockerNetwork.IPAMConfig{},
-			},
-			Labels: dt.getLabels(),
-		},
-	}
+	return dt.newNetworkBuilder(networkName)
 }
 
 // CreateSimpleNetwork creates a bridged Network with the given name, subnet mask and ip range.
@@ -242,11 +353,18 @@ func (dt *Session) CreateSimpleNetwork(networkName, subNet, ipRange string) Netw
 	ctx, cancel := context.WithTimeout(context.Background(), cleanerTimeout)
 	defer cancel()
 
-	cleaner := newCleaner(ctx, dt)
-	cleaner.cleanupTestNetwork()
+	return dt.CreateSimpleNetworkCtx(ctx, networkName, subNet, ipRange)
+}
 
+// CreateSimpleNetworkCtx is like CreateSimpleNetwork, the given context is used to remove previous test networks.
+func (dt *Session) CreateSimpleNetworkCtx(ctx context.Context, networkName, subNet, ipRange string) NetworkBuilder {
+	return dt.CreateBasicNetworkCtx(ctx, networkName).subnet(subNet, ipRange)
+}
+
+func (dt *Session) newNetworkBuilder(networkName string) NetworkBuilder {
 	return NetworkBuilder{
 		clientEnabled: dt.clientEnabled,
+		subnetPool:    dt.subnetPool,
 		Name:          networkName,
 		Options: types.NetworkCreate{
 			CheckDuplicate: true,
@@ -254,12 +372,7 @@ func (dt *Session) CreateSimpleNetwork(networkName, subNet, ipRange string) Netw
 			Driver:         "bridge",
 			IPAM: &dockerNetwork.IPAM{
 				Driver: "default",
-				Config: []dockerNetwork.IPAMConfig{
-					{
-						Subnet:  subNet,
-						IPRange: ipRange,
-					},
-				},
+				Config: []dockerNetwork.IPAMConfig{},
 			},
 			Labels: dt.getLabels(),
 		},
@@ -270,6 +383,7 @@ func (dt *Session) CreateSimpleNetwork(networkName, subNet, ipRange string) Netw
 func (dt *Session) NewContainerBuilder() *ContainerBuilder {
 	return &ContainerBuilder{
 		clientEnabled: dt.clientEnabled,
+		session:       dt,
 		sessionID:     dt.ID,
 		ContainerConfig: &container.Config{
 			Labels: dt.getLabels(),
@@ -279,9 +393,12 @@ func (dt *Session) NewContainerBuilder() *ContainerBuilder {
 	}
 }
 
-func getBasicFilterArgs() filters.Args {
-	filterArgs := filters.NewArgs()
-	filterArgs.Add("label", fmt.Sprintf("%s=%s", mainLabel, defaultMainLabelValue))
+// labelFilterArgs filters the components labelled with the given main label value, see SetLabel.
+func labelFilterArgs(label string) filters.Args {
+	return filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", mainLabel, label)))
+}
 
-	return filterArgs
+// sessionFilterArgs filters the components of this session.
+func (dt *Session) sessionFilterArgs() filters.Args {
+	return filterSessionID(labelFilterArgs(dt.mainLabel), dt.ID)
 }
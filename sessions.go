@@ -0,0 +1,260 @@
+package dockertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	"github.com/docker/docker/client"
+)
+
+// ErrSessionNotFound is returned if no component of a session exists on the docker host.
+var ErrSessionNotFound = errors.New("session not found")
+
+const sessionIDLayout = "20060102150405"
+
+// Sessions gives access to the sessions of all test runs on the docker host, including finished or crashed ones.
+// It is meant for tooling that cleans up leftovers, like the dockertest command.
+type Sessions struct {
+	dockerClient *client.Client
+	logger       *slog.Logger
+}
+
+// SessionInfo describes the components of a session found on the docker host.
+type SessionInfo struct {
+	ID string
+	// Label is the value of the main label, see Session.SetLabel.
+	Label      string
+	Created    time.Time
+	Containers []SessionContainer
+	Networks   []string
+	Volumes    []string
+}
+
+// SessionContainer is a container of a session.
+type SessionContainer struct {
+	ID    string
+	Name  string
+	Image string
+	// State is the containers state, like running or exited.
+	State string
+	// Status is the human readable status as shown by docker ps.
+	Status string
+	// Reusable containers are kept by Cleanup, see ContainerBuilder.Reuse.
+	Reusable bool
+}
+
+// Running returns the number of running containers of the session.
+func (s SessionInfo) Running() int {
+	var running int
+
+	for _, c := range s.Containers {
+		if c.State == "running" {
+			running++
+		}
+	}
+
+	return running
+}
+
+// NewSessions connects to the docker host configured in the environment.
+// Diagnostics of cleanups are written to the logger, nil discards them.
+func NewSessions(logger *slog.Logger) (*Sessions, error) {
+	dockerClient, err := client.NewClientWithOpts(client.FromEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	if logger == nil {
+		logger = newDiscardLogger()
+	}
+
+	return &Sessions{dockerClient: dockerClient, logger: logger}, nil
+}
+
+// List returns all sessions that have containers, networks or volumes on the docker host, oldest first.
+func (s *Sessions) List(ctx context.Context) ([]SessionInfo, error) {
+	byKey := map[string]*SessionInfo{}
+	filterArgs := filters.NewArgs(filters.Arg("label", sessionLabel))
+
+	session := func(labels map[string]string) *SessionInfo {
+		key := labels[mainLabel] + "\x00" + labels[sessionLabel]
+		if info, ok := byKey[key]; ok {
+			return info
+		}
+
+		info := &SessionInfo{ID: labels[sessionLabel], Label: labels[mainLabel]}
+		info.Created, _ = time.ParseInLocation(sessionIDLayout, info.ID, time.Local)
+		byKey[key] = info
+
+		return info
+	}
+
+	containers, err := s.dockerClient.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: filterArgs})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range containers {
+		info := session(c.Labels)
+		info.Containers = append(info.Containers, SessionContainer{
+			ID:       c.ID,
+			Name:     strings.TrimPrefix(c.Names[0], "/"),
+			Image:    c.Image,
+			State:    c.State,
+			Status:   c.Status,
+			Reusable: c.Labels[reuseLabel] != "",
+		})
+	}
+
+	networks, err := s.dockerClient.NetworkList(ctx, types.NetworkListOptions{Filters: filterArgs})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range networks {
+		info := session(n.Labels)
+		info.Networks = append(info.Networks, n.Name)
+	}
+
+	volumes, err := s.dockerClient.VolumeList(ctx, volume.ListOptions{Filters: filterArgs})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range volumes.Volumes {
+		info := session(v.Labels)
+		info.Volumes = append(info.Volumes, v.Name)
+	}
+
+	sessions := make([]SessionInfo, 0, len(byKey))
+	for _, info := range byKey {
+		sessions = append(sessions, *info)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].ID < sessions[j].ID
+	})
+
+	return sessions, nil
+}
+
+// Get returns the session with the given ID.
+func (s *Sessions) Get(ctx context.Context, id string) (SessionInfo, error) {
+	sessions, err := s.List(ctx)
+	if err != nil {
+		return SessionInfo{}, err
+	}
+
+	for _, info := range sessions {
+		if info.ID == id {
+			return info, nil
+		}
+	}
+
+	return SessionInfo{}, fmt.Errorf("%w: '%s'", ErrSessionNotFound, id)
+}
+
+// Remove stops and removes the containers of the session, including reusable ones,
+// and removes its volumes, images and networks.
+func (s *Sessions) Remove(ctx context.Context, id string) error {
+	info, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	s.remove(ctx, info, true)
+
+	return nil
+}
+
+// PruneReusable stops and removes the reusable containers of all sessions and returns them.
+// Reusable networks are removed as well.
+func (s *Sessions) PruneReusable(ctx context.Context) ([]SessionContainer, error) {
+	sessions, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []SessionContainer
+
+	for _, info := range sessions {
+		for _, c := range info.Containers {
+			if !c.Reusable {
+				continue
+			}
+
+			err := s.dockerClient.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{RemoveVolumes: true, Force: true})
+			if err != nil {
+				return pruned, err
+			}
+
+			pruned = append(pruned, c)
+		}
+	}
+
+	removeNetworks(ctx, filters.NewArgs(filters.Arg("label", reuseLabel)), s.dockerClient, false, s.logger)
+
+	return pruned, nil
+}
+
+// Prune removes all sessions created before the given age and returns them. Reusable containers are kept.
+func (s *Sessions) Prune(ctx context.Context, olderThan time.Duration) ([]SessionInfo, error) {
+	sessions, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var pruned []SessionInfo
+
+	for _, info := range sessions {
+		if info.Created.IsZero() || time.Since(info.Created) < olderThan {
+			continue
+		}
+
+		s.remove(ctx, info, false)
+		pruned = append(pruned, info)
+	}
+
+	return pruned, nil
+}
+
+// WriteLogs writes the logs of all containers of the session, like Session.WriteContainerLogs.
+func (s *Sessions) WriteLogs(ctx context.Context, w io.Writer, id string) error {
+	info, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for _, sessionContainer := range info.Containers {
+		c := &Container{Name: sessionContainer.Name, containerID: sessionContainer.ID}
+
+		log, err := getContainerLog(ctx, s.dockerClient, c)
+		if err != nil {
+			return err
+		}
+
+		writeLog(w, c, log, s.logger)
+	}
+
+	return nil
+}
+
+func (s *Sessions) remove(ctx context.Context, info SessionInfo, force bool) {
+	logger := s.logger.With(slog.String("session", info.ID))
+	cleaner := newSessionCleaner(ctx, s.dockerClient, info.Label, logger)
+	cleaner.force = force
+	cleaner.stopSessionContainers(info.ID)
+	cleaner.removeDockerTestContainers(info.ID)
+	cleaner.removeSessionVolumes(info.ID)
+	cleaner.removeSessionImages(info.ID)
+	cleaner.removeSessionNetworks(info.ID)
+}
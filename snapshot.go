@@ -0,0 +1,183 @@
+package dockertest
+
+import (
+	"context"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	dockerNetwork "github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/mohae/deepcopy"
+)
+
+// Snapshot is an image committed from a container, see Container.Commit and Session.Restore.
+type Snapshot struct {
+	// Image is the tag of the snapshot image.
+	Image string
+	// ImageID is the ID of the snapshot image.
+	ImageID string
+	// Persistent snapshots are not removed on Cleanup.
+	Persistent       bool
+	config           *container.Config
+	hostConfig       *container.HostConfig
+	networkingConfig *dockerNetwork.NetworkingConfig
+}
+
+// Commit snapshots the containers filesystem into an image with the given tag, for example to start
+// many containers from a seeded database. The image is labelled with the session, so Cleanup removes it.
+// Snapshotting a running container pauses it while committing.
+func (c Container) Commit(ctx context.Context, tag string) (*Snapshot, error) {
+	return c.commit(ctx, tag, false)
+}
+
+// CommitPersistent is like Commit, but the image is not labelled with the session and outlives Cleanup.
+func (c Container) CommitPersistent(ctx context.Context, tag string) (*Snapshot, error) {
+	return c.commit(ctx, tag, true)
+}
+
+// Restore returns a ContainerBuilder configured like the container the snapshot was taken from,
+// but using the snapshot image and labelled with this session. The name is left empty and published ports
+// are bound to random host ports, since the original container may still exist. Use BindPort to bind
+// a fixed host port again.
+func (dt *Session) Restore(snapshot *Snapshot) *ContainerBuilder {
+	b := dt.NewContainerBuilder()
+
+	b.ContainerConfig = copyConfig(snapshot.config)
+	b.ContainerConfig.Image = snapshot.Image
+	b.ContainerConfig.Labels = withoutDockertestLabels(b.ContainerConfig.Labels)
+
+	for k, v := range dt.getLabels() {
+		b.ContainerConfig.Labels[k] = v
+	}
+
+	b.HostConfig = copyHostConfig(snapshot.hostConfig)
+
+	b.NetworkingConfig = &dockerNetwork.NetworkingConfig{EndpointsConfig: map[string]*dockerNetwork.EndpointSettings{}}
+	for name, endpoint := range snapshot.networkingConfig.EndpointsConfig {
+		b.NetworkingConfig.EndpointsConfig[name] = &dockerNetwork.EndpointSettings{
+			NetworkID: endpoint.NetworkID,
+			Aliases:   append([]string{}, endpoint.Aliases...),
+		}
+	}
+
+	return b
+}
+
+func (c Container) commit(ctx context.Context, tag string, persistent bool) (*Snapshot, error) {
+	inspectResult, err := c.dockerClient.ContainerInspect(ctx, c.containerID)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := withoutDockertestLabels(inspectResult.Config.Labels)
+	if !persistent && c.session != nil {
+		for k, v := range c.session.getLabels() {
+			labels[k] = v
+		}
+	}
+
+	imageConfig := copyConfig(inspectResult.Config)
+	imageConfig.Labels = labels
+
+	resp, err := c.dockerClient.ContainerCommit(ctx, c.containerID, types.ContainerCommitOptions{
+		Reference: tag,
+		Comment:   "snapshot of " + c.Name,
+		Pause:     true,
+		Config:    imageConfig,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		Image:            tag,
+		ImageID:          resp.ID,
+		Persistent:       persistent,
+		config:           inspectResult.Config,
+		hostConfig:       inspectResult.HostConfig,
+		networkingConfig: snapshotNetworkingConfig(inspectResult),
+	}, nil
+}
+
+// snapshotNetworkingConfig returns the networks of the container with the aliases given on creation,
+// the addresses are left out, since they are taken by the original container.
+func snapshotNetworkingConfig(inspectResult types.ContainerJSON) *dockerNetwork.NetworkingConfig {
+	config := &dockerNetwork.NetworkingConfig{EndpointsConfig: map[string]*dockerNetwork.EndpointSettings{}}
+	if inspectResult.NetworkSettings == nil {
+		return config
+	}
+
+	shortID := inspectResult.ID
+	if len(shortID) > 12 { //nolint:gomnd
+		shortID = shortID[:12]
+	}
+
+	for name, endpoint := range inspectResult.NetworkSettings.Networks {
+		var aliases []string
+
+		for _, alias := range endpoint.Aliases {
+			// docker adds the short container ID as alias.
+			if alias != shortID {
+				aliases = append(aliases, alias)
+			}
+		}
+
+		config.EndpointsConfig[name] = &dockerNetwork.EndpointSettings{NetworkID: endpoint.NetworkID, Aliases: aliases}
+	}
+
+	return config
+}
+
+// copyConfig copies the container config, the hostname is reset since docker sets it to the container ID.
+func copyConfig(config *container.Config) *container.Config {
+	c := *config
+	c.Hostname = ""
+	c.Env = copyStrings(config.Env)
+	c.Cmd = copyStrings(config.Cmd)
+	c.Entrypoint = copyStrings(config.Entrypoint)
+	c.ExposedPorts = deepcopy.Copy(config.ExposedPorts).(nat.PortSet) //nolint:forcetypeassert
+	c.Volumes = deepcopy.Copy(config.Volumes).(map[string]struct{})   //nolint:forcetypeassert
+	c.Labels = map[string]string{}
+
+	for k, v := range config.Labels {
+		c.Labels[k] = v
+	}
+
+	return &c
+}
+
+// copyHostConfig deep-copies the host config, so builders restored from the same snapshot do not share
+// port bindings, binds or other maps and slices. The fixed host ports are cleared, they are taken by the original.
+func copyHostConfig(config *container.HostConfig) *container.HostConfig {
+	c := deepcopy.Copy(config).(*container.HostConfig) //nolint:forcetypeassert
+
+	for _, bindings := range c.PortBindings {
+		for i := range bindings {
+			bindings[i].HostPort = ""
+		}
+	}
+
+	return c
+}
+
+func withoutDockertestLabels(labels map[string]string) map[string]string {
+	filtered := map[string]string{}
+
+	for k, v := range labels {
+		if k != mainLabel && !strings.HasPrefix(k, mainLabel+"-") {
+			filtered[k] = v
+		}
+	}
+
+	return filtered
+}
+
+// copyStrings copies the slice, keeping nil apart from empty, since an empty entrypoint overrides the images one.
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+
+	return append([]string{}, s...)
+}
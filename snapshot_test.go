@@ -0,0 +1,51 @@
+package dockertest
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	dockerNetwork "github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestRestore(t *testing.T) {
+	dt := &Session{ID: "session", mainLabel: defaultMainLabelValue}
+	snapshot := &Snapshot{
+		Image: "snapshot:latest",
+		config: &container.Config{
+			Image:        "postgres",
+			ExposedPorts: nat.PortSet{"5432/tcp": {}},
+		},
+		hostConfig: &container.HostConfig{
+			Binds:        []string{"/data:/var/lib/postgresql/data"},
+			PortBindings: nat.PortMap{"5432/tcp": {{HostIP: "127.0.0.1", HostPort: "5432"}}},
+		},
+		networkingConfig: &dockerNetwork.NetworkingConfig{},
+	}
+
+	first := dt.Restore(snapshot)
+	first.BindPort("8080/tcp", "8080").ExposePort("8080/tcp").Mount("/tmp", "/tmp")
+
+	second := dt.Restore(snapshot)
+
+	if _, ok := second.HostConfig.PortBindings["8080/tcp"]; ok {
+		t.Fatal("expected the port binding of the first restore not to leak into the second")
+	}
+
+	if _, ok := second.ContainerConfig.ExposedPorts["8080/tcp"]; ok {
+		t.Fatal("expected the exposed port of the first restore not to leak into the second")
+	}
+
+	if len(second.HostConfig.Binds) != 1 {
+		t.Fatalf("expected the binds of the snapshot only, got %v", second.HostConfig.Binds)
+	}
+
+	want := nat.PortBinding{HostIP: "127.0.0.1"}
+	if got := second.HostConfig.PortBindings["5432/tcp"]; len(got) != 1 || got[0] != want {
+		t.Fatalf("expected the fixed host port to be cleared, got %v", got)
+	}
+
+	if snapshot.hostConfig.PortBindings["5432/tcp"][0].HostPort != "5432" {
+		t.Fatal("expected the snapshot to keep its host port")
+	}
+}
@@ -0,0 +1,318 @@
+package dockertest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrInvalidSpec is returned if a Spec references unknown components or contains dependency cycles.
+var ErrInvalidSpec = errors.New("invalid spec")
+
+// Spec declaratively describes a test environment of networks, volumes and containers.
+// It can be written in YAML or JSON and is brought up by Session.Apply.
+type Spec struct {
+	// Variables are default values for ${VAR} interpolation, they are overruled by variables passed to ParseSpec
+	// and by the environment.
+	Variables map[string]string      `yaml:"variables" json:"variables"`
+	Networks  map[string]NetworkSpec `yaml:"networks" json:"networks"`
+	Volumes   map[string]VolumeSpec  `yaml:"volumes" json:"volumes"`
+	// Templates are container definitions which are not started, containers inherit them by naming them in Extends.
+	Templates  map[string]ContainerSpec `yaml:"templates" json:"templates"`
+	Containers map[string]ContainerSpec `yaml:"containers" json:"containers"`
+	// Test names the container that runs the tests, Session.RunSpec runs it to completion after all others are ready.
+	Test    string `yaml:"test" json:"test"`
+	baseDir string
+}
+
+// NetworkSpec describes a network of a Spec.
+type NetworkSpec struct {
+	Subnet     string `yaml:"subnet" json:"subnet"`
+	IPRange    string `yaml:"ipRange" json:"ipRange"`
+	AutoSubnet bool   `yaml:"autoSubnet" json:"autoSubnet"`
+	Internal   bool   `yaml:"internal" json:"internal"`
+}
+
+// VolumeSpec describes a named volume of a Spec.
+type VolumeSpec struct {
+	Driver string `yaml:"driver" json:"driver"`
+}
+
+// ContainerSpec describes a container of a Spec.
+// DependsOn and Wait describe the startup of the container and are not inherited from templates.
+type ContainerSpec struct {
+	// Extends names the template this container inherits its configuration from.
+	Extends string `yaml:"extends" json:"extends"`
+	Image   string `yaml:"image" json:"image"`
+	// Build builds the image of the container, the built image is removed on cleanup.
+	Build      *BuildSpec        `yaml:"build" json:"build"`
+	Cmd        []string          `yaml:"cmd" json:"cmd"`
+	Env        map[string]string `yaml:"env" json:"env"`
+	WorkingDir string            `yaml:"workingDir" json:"workingDir"`
+	// Ports binds host ports to container ports like "[ip:][hostPort:]containerPort[/protocol]",
+	// for example "8080:80", "127.0.0.1:8080:80/udp" or "9000-9002:9000-9002". Without a host port,
+	// a random one is bound.
+	Ports  []string `yaml:"ports" json:"ports"`
+	Expose []string `yaml:"expose" json:"expose"`
+	// Mounts mounts local paths or named volumes of the Spec, for example "./data:/data" or "db-data:/var/lib/db:ro".
+	Mounts []string `yaml:"mounts" json:"mounts"`
+	// Networks the container is attached to, it is reachable by its name in all of them.
+	Networks []string `yaml:"networks" json:"networks"`
+	// Aliases are further names by network the container is reachable by.
+	Aliases   map[string][]string `yaml:"aliases" json:"aliases"`
+	Health    *HealthSpec         `yaml:"health" json:"health"`
+	DependsOn []string            `yaml:"dependsOn" json:"dependsOn"`
+	Wait      *WaitSpec           `yaml:"wait" json:"wait"`
+}
+
+// BuildSpec describes how to build the image of a container.
+type BuildSpec struct {
+	// Context is the directory sent to the docker daemon, relative paths are resolved like mounts.
+	Context    string            `yaml:"context" json:"context"`
+	Dockerfile string            `yaml:"dockerfile" json:"dockerfile"`
+	Args       map[string]string `yaml:"args" json:"args"`
+	Target     string            `yaml:"target" json:"target"`
+}
+
+// HealthSpec describes the health check of a container.
+type HealthSpec struct {
+	// Test is the raw docker health check test, for example [CMD, curl, -f, http://localhost], it overrules Cmd and ShellCmd.
+	Test        []string      `yaml:"test" json:"test"`
+	Cmd         string        `yaml:"cmd" json:"cmd"`
+	ShellCmd    string        `yaml:"shellCmd" json:"shellCmd"`
+	Interval    time.Duration `yaml:"interval" json:"interval"`
+	Timeout     time.Duration `yaml:"timeout" json:"timeout"`
+	StartPeriod time.Duration `yaml:"startPeriod" json:"startPeriod"`
+	Retries     int           `yaml:"retries" json:"retries"`
+	Disable     bool          `yaml:"disable" json:"disable"`
+}
+
+// WaitSpec describes what Apply waits for after starting a container, before it starts its dependents.
+type WaitSpec struct {
+	Healthy bool   `yaml:"healthy" json:"healthy"`
+	Log     string `yaml:"log" json:"log"`
+	// Exit waits for the container to exit with exit code 0.
+	Exit    bool          `yaml:"exit" json:"exit"`
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// LoadSpec reads a YAML or JSON Spec from the given file.
+// Relative mount paths are resolved relative to the directory of the file.
+func LoadSpec(path string, vars map[string]string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := ParseSpec(data, vars)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing spec '%s': %w", path, err)
+	}
+
+	spec.baseDir, err = filepath.Abs(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// ParseSpec parses a YAML or JSON Spec.
+// ${VAR} and ${VAR:-default} expressions are replaced by the given variables,
+// the environment or the variables section of the spec, in this order of precedence.
+// $$ is replaced by a literal $, any other $ is kept, so shell commands can use $1 or $HOSTNAME.
+func ParseSpec(data []byte, vars map[string]string) (*Spec, error) {
+	var defaults struct {
+		Variables map[string]string `yaml:"variables"`
+	}
+
+	err := yaml.Unmarshal(data, &defaults)
+	if err != nil {
+		return nil, err
+	}
+
+	expanded, err := interpolate(string(data), func(name string) (string, bool) {
+		if v, ok := vars[name]; ok {
+			return v, true
+		}
+
+		if v, ok := os.LookupEnv(name); ok {
+			return v, true
+		}
+
+		v, ok := defaults.Variables[name]
+
+		return v, ok
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSpec, err)
+	}
+
+	var spec Spec
+
+	err = yaml.Unmarshal([]byte(expanded), &spec)
+	if err != nil {
+		return nil, err
+	}
+
+	spec.baseDir, err = os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	err = spec.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return &spec, nil
+}
+
+func (s *Spec) validate() error {
+	for name, c := range s.Containers {
+		err := s.validateContainer(name, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	for name, t := range s.Templates {
+		err := s.validateContainer(name, t)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.validateTest()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.startOrder()
+
+	return err
+}
+
+func (s *Spec) validateTest() error {
+	if s.Test == "" {
+		return nil
+	}
+
+	if _, ok := s.Containers[s.Test]; !ok {
+		return fmt.Errorf("%w: test container '%s' is not defined", ErrInvalidSpec, s.Test)
+	}
+
+	for name, c := range s.Containers {
+		for _, d := range c.DependsOn {
+			if d == s.Test {
+				return fmt.Errorf("%w: '%s' depends on the test container '%s'", ErrInvalidSpec, name, s.Test)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *Spec) validateContainer(name string, c ContainerSpec) error {
+	if _, ok := s.Templates[c.Extends]; c.Extends != "" && !ok {
+		return fmt.Errorf("%w: '%s' extends unknown template '%s'", ErrInvalidSpec, name, c.Extends)
+	}
+
+	for _, n := range c.Networks {
+		if _, ok := s.Networks[n]; !ok {
+			return fmt.Errorf("%w: '%s' uses unknown network '%s'", ErrInvalidSpec, name, n)
+		}
+	}
+
+	for n := range c.Aliases {
+		if _, ok := s.Networks[n]; !ok {
+			return fmt.Errorf("%w: '%s' has aliases for unknown network '%s'", ErrInvalidSpec, name, n)
+		}
+	}
+
+	for _, d := range c.DependsOn {
+		if _, ok := s.Containers[d]; !ok {
+			return fmt.Errorf("%w: '%s' depends on unknown container '%s'", ErrInvalidSpec, name, d)
+		}
+	}
+
+	for _, port := range c.Ports {
+		_, err := nat.ParsePortSpec(port)
+		if err != nil {
+			return fmt.Errorf("%w: '%s' has invalid port '%s': %w", ErrInvalidSpec, name, port, err)
+		}
+	}
+
+	return nil
+}
+
+// startOrder returns the container names ordered so that every container comes after its dependencies.
+func (s *Spec) startOrder() ([]string, error) {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	var (
+		order = make([]string, 0, len(s.Containers))
+		state = map[string]int{}
+		visit func(name string, path []string) error
+	)
+
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: dependency cycle %s", ErrInvalidSpec, strings.Join(append(path, name), " -> "))
+		}
+
+		state[name] = visiting
+
+		for _, dependency := range s.Containers[name].DependsOn {
+			if err := visit(dependency, append(path, name)); err != nil {
+				return err
+			}
+		}
+
+		state[name] = visited
+		order = append(order, name)
+
+		return nil
+	}
+
+	for _, name := range sortedKeys(s.Containers) {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// resolvePath makes relative local paths absolute using the directory of the spec.
+func (s *Spec) resolvePath(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+
+	return filepath.Join(s.baseDir, p)
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
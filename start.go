@@ -0,0 +1,394 @@
+package dockertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// ErrDependencyCycle is returned from StartAll if containers depend on each other.
+var ErrDependencyCycle = errors.New("dependency cycle")
+
+// ErrDependencyFailed is the cause of a StartError of a container that was not started, because a dependency failed.
+var ErrDependencyFailed = errors.New("dependency failed")
+
+const startErrorLogTailLines = 20
+
+type conditionKind int
+
+const (
+	conditionStarted conditionKind = iota
+	conditionHealthy
+	conditionLogContains
+	conditionExitedSuccessfully
+	conditionExited
+)
+
+// Condition describes when a container is ready, for example for the containers depending on it.
+type Condition struct {
+	kind    conditionKind
+	search  string
+	timeout time.Duration
+}
+
+// ConditionStarted is met as soon as the container was started.
+func ConditionStarted() Condition {
+	return Condition{kind: conditionStarted}
+}
+
+// ConditionHealthy is met when the containers health check reports healthy.
+func ConditionHealthy() Condition {
+	return Condition{kind: conditionHealthy}
+}
+
+// ConditionLogContains is met when the containers log output contains the given search string.
+func ConditionLogContains(search string) Condition {
+	return Condition{kind: conditionLogContains, search: search}
+}
+
+// ConditionExitedSuccessfully is met when the container exited with exit code 0.
+func ConditionExitedSuccessfully() Condition {
+	return Condition{kind: conditionExitedSuccessfully}
+}
+
+// WithTimeout limits the time waited for the condition, by default it is only limited by the context.
+func (c Condition) WithTimeout(d time.Duration) Condition {
+	c.timeout = d
+
+	return c
+}
+
+func (c Condition) String() string {
+	switch c.kind {
+	case conditionHealthy:
+		return "healthy"
+	case conditionLogContains:
+		return fmt.Sprintf("log contains '%s'", c.search)
+	case conditionExitedSuccessfully:
+		return "exited successfully"
+	case conditionExited:
+		return "exited"
+	default:
+		return "started"
+	}
+}
+
+// dependency is a container that must meet a condition before the depending container is started.
+type dependency struct {
+	container *Container
+	condition Condition
+}
+
+// StartError is returned by StartAll for every container that failed to start or to get ready.
+type StartError struct {
+	Container string
+	Err       error
+	// LogTail contains the last lines of the containers log output.
+	LogTail string
+}
+
+func (e *StartError) Error() string {
+	if e.LogTail == "" {
+		return fmt.Sprintf("container '%s': %v", e.Container, e.Err)
+	}
+
+	return fmt.Sprintf("container '%s': %v\n------ last log lines of '%s':\n%s", e.Container, e.Err, e.Container, e.LogTail)
+}
+
+func (e *StartError) Unwrap() error {
+	return e.Err
+}
+
+// DependsOn declares that the container may only be started by StartAll once the given container met the condition.
+func (b *ContainerBuilder) DependsOn(c *Container, condition Condition) *ContainerBuilder {
+	b.dependencies = append(b.dependencies, dependency{container: c, condition: condition})
+
+	return b
+}
+
+// ReadyWhen declares conditions the container must meet after starting, before StartAll considers it ready.
+// Containers depending on it are started not before it is ready.
+func (b *ContainerBuilder) ReadyWhen(conditions ...Condition) *ContainerBuilder {
+	b.readiness = append(b.readiness, conditions...)
+
+	return b
+}
+
+// StartAll starts all containers of the session that were not started by StartAll or Apply before.
+// Containers are started as soon as all their dependencies met their conditions, so independent
+// branches are started in parallel. On the first failure all pending starts are cancelled and
+// a joined error of StartErrors is returned, naming the failing containers and their last log lines.
+func (dt *Session) StartAll(ctx context.Context) error {
+	return dt.startGraph(ctx, dt.pendingContainers())
+}
+
+func (dt *Session) register(c *Container) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	dt.containers = append(dt.containers, c)
+}
+
+func (dt *Session) pendingContainers() []*Container {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	var pending []*Container
+
+	for _, c := range dt.containers {
+		if !dt.started[c.containerID] {
+			pending = append(pending, c)
+		}
+	}
+
+	return pending
+}
+
+// containerStarted marks the container as started and follows its logs on the console and to the log consumers.
+func (dt *Session) containerStarted(c *Container) {
+	dt.mu.Lock()
+	consumed := dt.started[c.containerID]
+	dt.started[c.containerID] = true
+	dt.mu.Unlock()
+
+	if dt.console != nil {
+		dt.console.follow(dt.ctx, c)
+	}
+
+	if !consumed {
+		dt.consumeLogs(c)
+	}
+}
+
+// startNode is the state of one container while starting a graph.
+type startNode struct {
+	container *Container
+	done      chan struct{}
+	err       error
+}
+
+// readiness memorizes the result of waiting for a condition, so it is awaited only once for all dependents.
+type readiness struct {
+	once sync.Once
+	err  error
+}
+
+type startGraph struct {
+	session   *Session
+	nodes     map[string]*startNode
+	readiness sync.Map
+	cancel    context.CancelFunc
+}
+
+func (dt *Session) startGraph(ctx context.Context, containers []*Container) error {
+	err := detectDependencyCycle(containers)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	g := &startGraph{session: dt, nodes: map[string]*startNode{}, cancel: cancel}
+	for _, c := range containers {
+		g.nodes[c.containerID] = &startNode{container: c, done: make(chan struct{})}
+	}
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(g.nodes))
+
+	for _, node := range g.nodes {
+		go func(node *startNode) {
+			defer wg.Done()
+			defer close(node.done)
+
+			node.err = g.start(ctx, node)
+			if node.err != nil {
+				cancel()
+			}
+		}(node)
+	}
+
+	wg.Wait()
+
+	return g.errors()
+}
+
+func (g *startGraph) start(ctx context.Context, node *startNode) error {
+	for _, d := range node.container.dependencies {
+		if dependencyNode, ok := g.nodes[d.container.containerID]; ok {
+			<-dependencyNode.done
+
+			if dependencyNode.err != nil {
+				return fmt.Errorf("%w: '%s'", ErrDependencyFailed, d.container.Name)
+			}
+		}
+
+		err := g.await(ctx, d.container, d.condition)
+		if err != nil {
+			return fmt.Errorf("%w: '%s' %s: %w", ErrDependencyFailed, d.container.Name, d.condition, err)
+		}
+	}
+
+	err := node.container.start(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, condition := range node.container.readiness {
+		err := g.await(ctx, node.container, condition)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (g *startGraph) await(ctx context.Context, c *Container, condition Condition) error {
+	key := fmt.Sprintf("%s:%v:%s", c.containerID, condition.kind, condition.search)
+	r, _ := g.readiness.LoadOrStore(key, &readiness{})
+	ready := r.(*readiness) //nolint:forcetypeassert
+
+	ready.once.Do(func() {
+		ready.err = waitForCondition(ctx, g.session.dockerClient, g.session.logger, c, condition)
+	})
+
+	return ready.err
+}
+
+// errors joins the errors of all failed containers. Failures caused by the cancellation after
+// the first failure and by failed dependencies are left out, as long as there are root causes.
+func (g *startGraph) errors() error {
+	var rootCauses, consequences []error
+
+	for _, node := range g.nodes {
+		if node.err == nil {
+			continue
+		}
+
+		startErr := &StartError{Container: node.container.Name, Err: node.err}
+
+		if errors.Is(node.err, ErrDependencyFailed) || errors.Is(node.err, context.Canceled) {
+			consequences = append(consequences, startErr)
+
+			continue
+		}
+
+		// wait errors carry the log tail already.
+		var waitErr *WaitError
+		if !errors.As(node.err, &waitErr) {
+			startErr.LogTail = containerLogTail(g.session.dockerClient, node.container, startErrorLogTailLines)
+		}
+		rootCauses = append(rootCauses, startErr)
+	}
+
+	if len(rootCauses) > 0 {
+		return errors.Join(rootCauses...)
+	}
+
+	return errors.Join(consequences...)
+}
+
+// waitForCondition waits until the condition is met, or returns a *WaitError.
+func waitForCondition(
+	ctx context.Context,
+	dockerClient *client.Client,
+	logger *slog.Logger,
+	c *Container,
+	condition Condition,
+) error {
+	if condition.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, condition.timeout)
+		defer cancel()
+	}
+
+	started := time.Now()
+
+	err := waitForConditionKind(ctx, dockerClient, logger, c, condition)
+	if err != nil {
+		return newWaitError(c, condition, started, err)
+	}
+
+	return nil
+}
+
+func waitForConditionKind(
+	ctx context.Context,
+	dockerClient *client.Client,
+	logger *slog.Logger,
+	c *Container,
+	condition Condition,
+) error {
+	switch condition.kind {
+	case conditionHealthy:
+		return waitForRunningContainer(ctx, containerIsHealthy, c)
+	case conditionLogContains:
+		return waitForContainerLog(ctx, condition.search, c)
+	case conditionExitedSuccessfully:
+		return waitForSuccessfulExit(ctx, dockerClient, logger, c)
+	case conditionExited:
+		return c.waitExit(ctx)
+	case conditionStarted:
+		if !waitForContainer(ctx, containerHasStarted, dockerClient, c.containerID, logger) {
+			return waitEndedError(ctx, ErrContainerStartTimeout)
+		}
+	}
+
+	return nil
+}
+
+func detectDependencyCycle(containers []*Container) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+
+	byID := map[string]*Container{}
+	for _, c := range containers {
+		byID[c.containerID] = c
+	}
+
+	state := map[string]int{}
+
+	var visit func(c *Container, path []string) error
+
+	visit = func(c *Container, path []string) error {
+		switch state[c.containerID] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("%w: %s", ErrDependencyCycle, strings.Join(append(path, c.Name), " -> "))
+		}
+
+		state[c.containerID] = visiting
+
+		for _, d := range c.dependencies {
+			if dependency, ok := byID[d.container.containerID]; ok {
+				if err := visit(dependency, append(path, c.Name)); err != nil {
+					return err
+				}
+			}
+		}
+
+		state[c.containerID] = visited
+
+		return nil
+	}
+
+	for _, c := range containers {
+		if err := visit(c, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package dockertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+)
+
+// ErrImageNotFound is returned from Build if the image of the container does not exist.
+var ErrImageNotFound = errors.New("image not found")
+
+// ErrNameConflict is returned from Build if a container with the same name exists already.
+var ErrNameConflict = errors.New("container name is already in use")
+
+// ErrPortAllocated is returned from Start if a published host port is used by another container or process.
+// It is detected by the error message of the docker daemon, so it is best-effort.
+var ErrPortAllocated = errors.New("port is already allocated")
+
+// ErrOOMKilled is returned from waits for a container that was killed because it ran out of memory.
+var ErrOOMKilled = errors.New("container was killed because it ran out of memory")
+
+// ContainerStatus is the status of a container as reported by docker.
+type ContainerStatus string
+
+const (
+	StatusCreated    ContainerStatus = "created"
+	StatusRunning    ContainerStatus = "running"
+	StatusPaused     ContainerStatus = "paused"
+	StatusRestarting ContainerStatus = "restarting"
+	StatusRemoving   ContainerStatus = "removing"
+	StatusExited     ContainerStatus = "exited"
+	StatusDead       ContainerStatus = "dead"
+)
+
+// ContainerState is the state of a container, see Container.State.
+type ContainerState struct {
+	Status ContainerStatus
+	// ExitCode is only meaningful if the container exited.
+	ExitCode  int
+	OOMKilled bool
+	// Error is the error docker reported for the container, like a failed start.
+	Error      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Health is starting, healthy or unhealthy. It is empty if the container has no health check.
+	Health              string
+	HealthFailingStreak int
+}
+
+// State returns the current state of the container.
+func (c Container) State(ctx context.Context) (ContainerState, error) {
+	inspectResult, err := c.dockerClient.ContainerInspect(ctx, c.containerID)
+	if err != nil {
+		return ContainerState{}, fmt.Errorf("%w: %w", ErrInspectingContainer, err)
+	}
+
+	if inspectResult.State == nil {
+		return ContainerState{}, ErrStateNotSet
+	}
+
+	state := ContainerState{
+		Status:     ContainerStatus(inspectResult.State.Status),
+		ExitCode:   inspectResult.State.ExitCode,
+		OOMKilled:  inspectResult.State.OOMKilled,
+		Error:      inspectResult.State.Error,
+		StartedAt:  parseStateTime(inspectResult.State.StartedAt),
+		FinishedAt: parseStateTime(inspectResult.State.FinishedAt),
+	}
+
+	if inspectResult.State.Health != nil {
+		state.Health = inspectResult.State.Health.Status
+		state.HealthFailingStreak = inspectResult.State.Health.FailingStreak
+	}
+
+	return state, nil
+}
+
+func (s ContainerState) String() string {
+	var sb strings.Builder
+
+	sb.WriteString(string(s.Status))
+
+	if s.Status == StatusExited || s.Status == StatusDead {
+		sb.WriteString(fmt.Sprintf(", exit code %v", s.ExitCode))
+	}
+
+	if s.OOMKilled {
+		sb.WriteString(", killed because out of memory")
+	}
+
+	if s.Error != "" {
+		sb.WriteString(fmt.Sprintf(", error: %s", s.Error))
+	}
+
+	if s.Health != "" {
+		sb.WriteString(fmt.Sprintf(", health %s (failing streak %v)", s.Health, s.HealthFailingStreak))
+	}
+
+	if !s.StartedAt.IsZero() {
+		sb.WriteString(", started " + s.StartedAt.Format(time.RFC3339))
+	}
+
+	if !s.FinishedAt.IsZero() {
+		sb.WriteString(", finished " + s.FinishedAt.Format(time.RFC3339))
+	}
+
+	return sb.String()
+}
+
+// parseStateTime parses the timestamps of the container state, docker reports the zero time for unset ones.
+func parseStateTime(value string) time.Time {
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil || t.IsZero() {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// createError wraps errors of creating a container with the matching sentinel error.
+func createError(image, name string, err error) error {
+	switch {
+	case errdefs.IsNotFound(err) && strings.Contains(err.Error(), "No such image"):
+		return fmt.Errorf("%w: '%s': %w", ErrImageNotFound, image, err)
+	case errdefs.IsConflict(err):
+		return fmt.Errorf("%w: '%s': %w", ErrNameConflict, name, err)
+	default:
+		return err
+	}
+}
+
+// startError wraps errors of starting a container with the matching sentinel error.
+// The daemon reports allocated ports as plain errors, so only the message can be matched. The messages are not
+// part of the API and differ between platforms, unmatched errors are returned as they are.
+func startError(name string, err error) error {
+	if strings.Contains(err.Error(), "port is already allocated") || strings.Contains(err.Error(), "address already in use") {
+		return fmt.Errorf("%w: container '%s': %w", ErrPortAllocated, name, err)
+	}
+
+	return err
+}
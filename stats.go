@@ -0,0 +1,368 @@
+package dockertest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+// ErrResourceLimitExceeded is returned from the assertions of StatsSampler if a container used more than expected.
+var ErrResourceLimitExceeded = errors.New("resource limit exceeded")
+
+// ErrNoStatsSamples is returned from the assertions of StatsSampler if no usage was recorded, so nothing can be asserted.
+var ErrNoStatsSamples = errors.New("no stats samples recorded")
+
+// ContainerStats is the resource usage of a container at a point in time.
+type ContainerStats struct {
+	Time time.Time
+	// CPUPercent is the cpu usage relative to a single cpu, so it may exceed 100 on multiple cpus.
+	CPUPercent float64
+	// MemoryUsage is the memory usage in bytes without the page cache, like docker stats shows it.
+	MemoryUsage uint64
+	MemoryLimit uint64
+	// NetworkRx and NetworkTx are the bytes received and sent on all networks.
+	NetworkRx uint64
+	NetworkTx uint64
+	// BlockRead and BlockWrite are the bytes read from and written to block devices.
+	BlockRead  uint64
+	BlockWrite uint64
+}
+
+// Stats returns the current resource usage of the running container.
+func (c Container) Stats(ctx context.Context) (ContainerStats, error) {
+	resp, err := c.dockerClient.ContainerStats(ctx, c.containerID, false)
+	if err != nil {
+		return ContainerStats{}, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	var v types.StatsJSON
+
+	err = json.NewDecoder(resp.Body).Decode(&v)
+	if err != nil {
+		return ContainerStats{}, err
+	}
+
+	return newContainerStats(v), nil
+}
+
+// SampleStats records the resource usage of the container in the given interval, until the context
+// is done, the sampler is stopped or the container stops. Docker updates the stats about once a second,
+// so shorter intervals have no effect.
+// The samples of the session containers are written to a stats.csv file by Session.CollectArtifacts.
+func (c Container) SampleStats(ctx context.Context, interval time.Duration) *StatsSampler {
+	ctx, cancel := context.WithCancel(ctx)
+
+	s := &StatsSampler{
+		container:   c.Name,
+		containerID: c.containerID,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+
+	if c.session != nil {
+		c.session.addStatsSampler(s)
+	}
+
+	go s.sample(ctx, c, interval)
+
+	return s
+}
+
+// StatsSampler collects the resource usage of a container, see Container.SampleStats.
+// It is safe for concurrent use.
+type StatsSampler struct {
+	container   string
+	containerID string
+	cancel      context.CancelFunc
+	done        chan struct{}
+	mu          sync.Mutex
+	samples     []ContainerStats
+	err         error
+}
+
+// Stop ends the sampling and waits until the last sample was recorded.
+func (s *StatsSampler) Stop() {
+	s.cancel()
+	<-s.done
+}
+
+// Err returns the error that ended the sampling, it is nil if the sampler was stopped or the container stopped.
+func (s *StatsSampler) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.err
+}
+
+// Samples returns the samples recorded so far.
+func (s *StatsSampler) Samples() []ContainerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]ContainerStats{}, s.samples...)
+}
+
+// MaxMemory returns the highest memory usage of all samples.
+func (s *StatsSampler) MaxMemory() uint64 {
+	var maxMemory uint64
+
+	for _, sample := range s.Samples() {
+		if sample.MemoryUsage > maxMemory {
+			maxMemory = sample.MemoryUsage
+		}
+	}
+
+	return maxMemory
+}
+
+// MeanCPU returns the average cpu usage of all samples.
+func (s *StatsSampler) MeanCPU() float64 {
+	samples := s.Samples()
+	if len(samples) == 0 {
+		return 0
+	}
+
+	var sum float64
+
+	for _, sample := range samples {
+		sum += sample.CPUPercent
+	}
+
+	return sum / float64(len(samples))
+}
+
+// AssertMaxMemory fails with ErrResourceLimitExceeded if the memory usage exceeded the limit in bytes.
+// It fails with ErrNoStatsSamples if nothing was recorded, or with the error that ended the sampling.
+func (s *StatsSampler) AssertMaxMemory(limit uint64) error {
+	err := s.assertSampled()
+	if err != nil {
+		return err
+	}
+
+	maxMemory := s.MaxMemory()
+	if maxMemory > limit {
+		return fmt.Errorf("%w: container '%s' used %v bytes of memory, expected at most %v",
+			ErrResourceLimitExceeded, s.container, maxMemory, limit)
+	}
+
+	return nil
+}
+
+// AssertMeanCPU fails with ErrResourceLimitExceeded if the average cpu usage exceeded the limit in percent.
+// It fails with ErrNoStatsSamples if nothing was recorded, or with the error that ended the sampling.
+func (s *StatsSampler) AssertMeanCPU(limit float64) error {
+	err := s.assertSampled()
+	if err != nil {
+		return err
+	}
+
+	meanCPU := s.MeanCPU()
+	if meanCPU > limit {
+		return fmt.Errorf("%w: container '%s' used %.2f%% cpu on average, expected at most %.2f%%",
+			ErrResourceLimitExceeded, s.container, meanCPU, limit)
+	}
+
+	return nil
+}
+
+// assertSampled fails if the sampling failed or did not record anything, a missing measurement is no pass.
+func (s *StatsSampler) assertSampled() error {
+	err := s.Err()
+	if err != nil {
+		return fmt.Errorf("error sampling stats of container '%s': %w", s.container, err)
+	}
+
+	if len(s.Samples()) == 0 {
+		return fmt.Errorf("%w: container '%s'", ErrNoStatsSamples, s.container)
+	}
+
+	return nil
+}
+
+func (s *StatsSampler) sample(ctx context.Context, c Container, interval time.Duration) {
+	defer close(s.done)
+
+	resp, err := c.dockerClient.ContainerStats(ctx, c.containerID, true)
+	if err != nil {
+		s.stopped(ctx, err)
+
+		return
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	decoder := json.NewDecoder(resp.Body)
+
+	var last time.Time
+
+	for {
+		var v types.StatsJSON
+
+		err := decoder.Decode(&v)
+		if err != nil {
+			s.stopped(ctx, err)
+
+			return
+		}
+
+		if !sampleDue(v, last, interval) {
+			continue
+		}
+
+		stats := newContainerStats(v)
+		last = stats.Time
+
+		s.mu.Lock()
+		s.samples = append(s.samples, stats)
+		s.mu.Unlock()
+	}
+}
+
+// sampleDue tells whether the stats are recorded. The first stats of a stream have no previous cpu usage
+// to calculate the percentage from, and stats read within the interval after the last sample are skipped.
+func sampleDue(v types.StatsJSON, last time.Time, interval time.Duration) bool {
+	if v.PreCPUStats.SystemUsage == 0 {
+		return false
+	}
+
+	return last.IsZero() || v.Read.Sub(last) >= interval
+}
+
+// stopped records the error that ended the sampling, unless the sampler was stopped or the container stopped.
+func (s *StatsSampler) stopped(ctx context.Context, err error) {
+	if ctx.Err() != nil || errors.Is(err, io.EOF) {
+		return
+	}
+
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+func (dt *Session) addStatsSampler(s *StatsSampler) {
+	dt.mu.Lock()
+	defer dt.mu.Unlock()
+
+	dt.statsSamplers = append(dt.statsSamplers, s)
+}
+
+// containerStatsSamples returns the samples of all samplers of the container, ordered by time.
+func (dt *Session) containerStatsSamples(containerID string) []ContainerStats {
+	dt.mu.Lock()
+	samplers := dt.statsSamplers
+	dt.mu.Unlock()
+
+	var samples []ContainerStats
+
+	for _, s := range samplers {
+		if s.containerID == containerID {
+			samples = append(samples, s.Samples()...)
+		}
+	}
+
+	sort.SliceStable(samples, func(i, j int) bool {
+		return samples[i].Time.Before(samples[j].Time)
+	})
+
+	return samples
+}
+
+func newContainerStats(v types.StatsJSON) ContainerStats {
+	stats := ContainerStats{
+		Time:        v.Read,
+		CPUPercent:  cpuPercent(v),
+		MemoryUsage: memoryUsage(v.MemoryStats),
+		MemoryLimit: v.MemoryStats.Limit,
+	}
+
+	for _, network := range v.Networks {
+		stats.NetworkRx += network.RxBytes
+		stats.NetworkTx += network.TxBytes
+	}
+
+	for _, entry := range v.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockRead += entry.Value
+		case "write":
+			stats.BlockWrite += entry.Value
+		}
+	}
+
+	return stats
+}
+
+// cpuPercent calculates the cpu usage since the previous read, like docker stats does.
+func cpuPercent(v types.StatsJSON) float64 {
+	cpuDelta := float64(v.CPUStats.CPUUsage.TotalUsage) - float64(v.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(v.CPUStats.SystemUsage) - float64(v.PreCPUStats.SystemUsage)
+
+	onlineCPUs := float64(v.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(v.CPUStats.CPUUsage.PercpuUsage))
+	}
+
+	if cpuDelta <= 0 || systemDelta <= 0 {
+		return 0
+	}
+
+	return cpuDelta / systemDelta * onlineCPUs * 100 //nolint:gomnd
+}
+
+// memoryUsage returns the memory usage without the inactive page cache, cgroup v1 and v2 name it differently.
+func memoryUsage(m types.MemoryStats) uint64 {
+	cache, ok := m.Stats["total_inactive_file"]
+	if !ok {
+		cache = m.Stats["inactive_file"]
+	}
+
+	if cache > m.Usage {
+		return m.Usage
+	}
+
+	return m.Usage - cache
+}
+
+func formatStatsCSV(samples []ContainerStats) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := csv.NewWriter(&buf)
+
+	_ = w.Write([]string{
+		"time", "cpu_percent", "memory_usage", "memory_limit", "network_rx", "network_tx", "block_read", "block_write",
+	})
+
+	for _, sample := range samples {
+		_ = w.Write([]string{
+			sample.Time.Format(time.RFC3339Nano),
+			strconv.FormatFloat(sample.CPUPercent, 'f', 2, 64), //nolint:gomnd
+			strconv.FormatUint(sample.MemoryUsage, 10),         //nolint:gomnd
+			strconv.FormatUint(sample.MemoryLimit, 10),         //nolint:gomnd
+			strconv.FormatUint(sample.NetworkRx, 10),           //nolint:gomnd
+			strconv.FormatUint(sample.NetworkTx, 10),           //nolint:gomnd
+			strconv.FormatUint(sample.BlockRead, 10),           //nolint:gomnd
+			strconv.FormatUint(sample.BlockWrite, 10),          //nolint:gomnd
+		})
+	}
+
+	w.Flush()
+
+	return buf.Bytes(), w.Error()
+}
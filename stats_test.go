@@ -0,0 +1,194 @@
+package dockertest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func TestCPUPercent(t *testing.T) {
+	stats := func(total, preTotal, system, preSystem uint64, onlineCPUs uint32, perCPU int) types.StatsJSON {
+		var v types.StatsJSON
+
+		v.CPUStats.CPUUsage.TotalUsage = total
+		v.CPUStats.CPUUsage.PercpuUsage = make([]uint64, perCPU)
+		v.CPUStats.SystemUsage = system
+		v.CPUStats.OnlineCPUs = onlineCPUs
+		v.PreCPUStats.CPUUsage.TotalUsage = preTotal
+		v.PreCPUStats.SystemUsage = preSystem
+
+		return v
+	}
+
+	tests := []struct {
+		name  string
+		stats types.StatsJSON
+		want  float64
+	}{
+		{name: "one of two cpus busy", stats: stats(1500, 500, 4000, 2000, 2, 0), want: 100},
+		{name: "quarter of one cpu", stats: stats(750, 500, 2000, 1000, 1, 0), want: 25},
+		{name: "cpus counted by per cpu usage", stats: stats(1500, 500, 4000, 2000, 0, 4), want: 200},
+		{name: "no cpu delta", stats: stats(500, 500, 4000, 2000, 2, 0), want: 0},
+		{name: "no system delta", stats: stats(1500, 500, 2000, 2000, 2, 0), want: 0},
+		{name: "counter reset", stats: stats(100, 500, 4000, 2000, 2, 0), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cpuPercent(tt.stats); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMemoryUsage(t *testing.T) {
+	tests := []struct {
+		name   string
+		memory types.MemoryStats
+		want   uint64
+	}{
+		{name: "cgroup v1", memory: types.MemoryStats{Usage: 1000, Stats: map[string]uint64{"total_inactive_file": 300}}, want: 700},
+		{name: "cgroup v2", memory: types.MemoryStats{Usage: 1000, Stats: map[string]uint64{"inactive_file": 200}}, want: 800},
+		{
+			name: "cgroup v1 preferred",
+			memory: types.MemoryStats{
+				Usage: 1000,
+				Stats: map[string]uint64{"total_inactive_file": 300, "inactive_file": 200},
+			},
+			want: 700,
+		},
+		{name: "no cache stats", memory: types.MemoryStats{Usage: 1000}, want: 1000},
+		{name: "cache above usage", memory: types.MemoryStats{Usage: 100, Stats: map[string]uint64{"inactive_file": 200}}, want: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := memoryUsage(tt.memory); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSampleDue(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	stats := func(read time.Time, preSystemUsage uint64) types.StatsJSON {
+		var v types.StatsJSON
+
+		v.Read = read
+		v.PreCPUStats.SystemUsage = preSystemUsage
+
+		return v
+	}
+
+	tests := []struct {
+		name  string
+		stats types.StatsJSON
+		last  time.Time
+		want  bool
+	}{
+		{name: "first of stream", stats: stats(start, 0), want: false},
+		{name: "first sample", stats: stats(start, 1000), want: true},
+		{name: "within interval", stats: stats(start.Add(time.Second), 1000), last: start, want: false},
+		{name: "at interval", stats: stats(start.Add(2*time.Second), 1000), last: start, want: true},
+		{name: "after interval", stats: stats(start.Add(3*time.Second), 1000), last: start, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sampleDue(tt.stats, tt.last, 2*time.Second); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFormatStatsCSV(t *testing.T) {
+	samples := []ContainerStats{
+		{
+			Time:        time.Date(2024, 1, 1, 12, 0, 0, 500, time.UTC),
+			CPUPercent:  12.345,
+			MemoryUsage: 1024,
+			MemoryLimit: 4096,
+			NetworkRx:   10,
+			NetworkTx:   20,
+			BlockRead:   30,
+			BlockWrite:  40,
+		},
+	}
+
+	got, err := formatStatsCSV(samples)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "time,cpu_percent,memory_usage,memory_limit,network_rx,network_tx,block_read,block_write\n" +
+		"2024-01-01T12:00:00.0000005Z,12.35,1024,4096,10,20,30,40\n"
+	if string(got) != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestStatsAssertions(t *testing.T) {
+	errStream := errors.New("stream broken")
+
+	tests := []struct {
+		name      string
+		sampler   *StatsSampler
+		memoryErr error
+		cpuErr    error
+	}{
+		{
+			name:    "within limits",
+			sampler: &StatsSampler{samples: []ContainerStats{{MemoryUsage: 100, CPUPercent: 10}}},
+		},
+		{
+			name:      "limits exceeded",
+			sampler:   &StatsSampler{samples: []ContainerStats{{MemoryUsage: 100, CPUPercent: 10}, {MemoryUsage: 300, CPUPercent: 70}}},
+			memoryErr: ErrResourceLimitExceeded,
+			cpuErr:    ErrResourceLimitExceeded,
+		},
+		{
+			name:      "no samples",
+			sampler:   &StatsSampler{},
+			memoryErr: ErrNoStatsSamples,
+			cpuErr:    ErrNoStatsSamples,
+		},
+		{
+			name:      "sampling failed",
+			sampler:   &StatsSampler{samples: []ContainerStats{{MemoryUsage: 100}}, err: errStream},
+			memoryErr: errStream,
+			cpuErr:    errStream,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.sampler.AssertMaxMemory(200); !errors.Is(err, tt.memoryErr) || (tt.memoryErr == nil) != (err == nil) {
+				t.Fatalf("AssertMaxMemory: expected %v, got %v", tt.memoryErr, err)
+			}
+
+			if err := tt.sampler.AssertMeanCPU(30); !errors.Is(err, tt.cpuErr) || (tt.cpuErr == nil) != (err == nil) {
+				t.Fatalf("AssertMeanCPU: expected %v, got %v", tt.cpuErr, err)
+			}
+		})
+	}
+}
+
+func TestNewContainerStats(t *testing.T) {
+	var v types.StatsJSON
+
+	v.Networks = map[string]types.NetworkStats{"eth0": {RxBytes: 10, TxBytes: 20}, "eth1": {RxBytes: 1, TxBytes: 2}}
+	v.BlkioStats.IoServiceBytesRecursive = []types.BlkioStatEntry{
+		{Op: "Read", Value: 100}, {Op: "write", Value: 200}, {Op: "Total", Value: 300},
+	}
+
+	stats := newContainerStats(v)
+	if stats.NetworkRx != 11 || stats.NetworkTx != 22 || stats.BlockRead != 100 || stats.BlockWrite != 200 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,304 @@
+package dockertest
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/api/types"
+	dockerNetwork "github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/client"
+)
+
+// ErrNoFreeSubnet is returned when creating a network with AutoSubnet and all subnets of the pool are in use.
+var ErrNoFreeSubnet = errors.New("no free subnet left in pool")
+
+// ErrInvalidSubnetPool is returned from SetSubnetPool if the pool cannot be carved into subnets of the given size.
+var ErrInvalidSubnetPool = errors.New("invalid subnet pool")
+
+// ErrHostOutOfRange is returned from HostIP if the host number is not an address of the networks subnet.
+var ErrHostOutOfRange = errors.New("host number out of subnet range")
+
+// ErrUnknownSubnet is returned from HostIP if the network was created without an IPv4 subnet.
+var ErrUnknownSubnet = errors.New("subnet of network is unknown")
+
+const defaultSubnetPool = "172.28.0.0/14"
+const defaultSubnetPrefixLen = 24
+const ipv4Bits = 32
+
+// maxHostPrefixLen is the longest prefix of a subnet with host addresses besides network and broadcast address.
+const maxHostPrefixLen = 30
+
+// maxSubnetAttempts limits how often AutoSubnet tries another subnet, if the chosen one was taken in the meantime.
+const maxSubnetAttempts = 10
+
+// subnetAllocation serializes looking up free subnets and creating networks,
+// so parallel sessions in one process do not pick the same subnet.
+var subnetAllocation sync.Mutex
+
+// subnetPool is a range of IPv4 addresses carved into subnets of equal size.
+type subnetPool struct {
+	pool      *net.IPNet
+	prefixLen int
+}
+
+func newSubnetPool(cidr string, prefixLen int) (*subnetPool, error) {
+	_, pool, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, fmt.Errorf("%w '%s': %w", ErrInvalidSubnetPool, cidr, err)
+	}
+
+	poolPrefixLen, bits := pool.Mask.Size()
+	if bits != ipv4Bits || prefixLen < poolPrefixLen || prefixLen > ipv4Bits {
+		return nil, fmt.Errorf("%w '%s' carved into /%v", ErrInvalidSubnetPool, cidr, prefixLen)
+	}
+
+	return &subnetPool{pool: pool, prefixLen: prefixLen}, nil
+}
+
+func defaultSubnetPoolOrPanic() *subnetPool {
+	pool, err := newSubnetPool(defaultSubnetPool, defaultSubnetPrefixLen)
+	panicOnError(err)
+
+	return pool
+}
+
+// SetSubnetPool sets the range networks created with AutoSubnet pick their subnet from.
+// The pool is carved into subnets of the given prefix length, for example "172.28.0.0/14" into /24s.
+func (dt *Session) SetSubnetPool(cidr string, prefixLen int) error {
+	pool, err := newSubnetPool(cidr, prefixLen)
+	if err != nil {
+		return err
+	}
+
+	dt.subnetPool = pool
+
+	return nil
+}
+
+// AutoSubnet lets Create pick a subnet of the sessions subnet pool that is not used by any other docker network.
+func (n NetworkBuilder) AutoSubnet() NetworkBuilder {
+	n.autoSubnet = true
+
+	return n
+}
+
+// HostIP returns the IP address with the given host number in the networks subnet, for example 10 in 172.28.3.0/24
+// results in 172.28.3.10. The network and broadcast addresses are out of range.
+func (n Network) HostIP(host int) (string, error) {
+	_, subnet, err := net.ParseCIDR(n.Subnet)
+	if err != nil || subnet.IP.To4() == nil {
+		return "", fmt.Errorf("%w: '%s'", ErrUnknownSubnet, n.NetworkName)
+	}
+
+	return hostIP(subnet, host)
+}
+
+func hostIP(subnet *net.IPNet, host int) (string, error) {
+	prefixLen, _ := subnet.Mask.Size()
+	if prefixLen > maxHostPrefixLen {
+		return "", fmt.Errorf("%w: %v in %s, the subnet has no host addresses", ErrHostOutOfRange, host, subnet)
+	}
+
+	size := uint64(1) << (ipv4Bits - prefixLen)
+
+	if host < 1 || uint64(host) > size-2 {
+		return "", fmt.Errorf("%w: %v in %s", ErrHostOutOfRange, host, subnet)
+	}
+
+	ip := binary.BigEndian.Uint32(subnet.IP.To4()) + uint32(host)
+
+	return uint32ToIP(ip).String(), nil
+}
+
+// resolveIP resolves IP addresses relative to the networks subnet like ".10" to absolute addresses.
+func (n Network) resolveIP(ipAddress string) (string, error) {
+	if !strings.HasPrefix(ipAddress, ".") {
+		return ipAddress, nil
+	}
+
+	host, err := strconv.Atoi(strings.TrimPrefix(ipAddress, "."))
+	if err != nil {
+		return "", fmt.Errorf("invalid relative IP address '%s': %w", ipAddress, err)
+	}
+
+	return n.HostIP(host)
+}
+
+func (n NetworkBuilder) createWithAutoSubnet(ctx context.Context) (types.NetworkCreateResponse, string, error) {
+	subnetAllocation.Lock()
+	defer subnetAllocation.Unlock()
+
+	used, err := usedSubnets(ctx, n.dockerClient)
+	if err != nil {
+		return types.NetworkCreateResponse{}, "", err
+	}
+
+	var resp types.NetworkCreateResponse
+
+	create := func(subnet *net.IPNet) error {
+		options := n.withIPAMConfig().Options
+		options.IPAM.Config = withAutoSubnet(options.IPAM.Config, subnet.String())
+
+		var createErr error
+		resp, createErr = n.dockerClient.NetworkCreate(ctx, n.Name, options)
+
+		return createErr
+	}
+
+	listUsed := func() ([]*net.IPNet, error) {
+		return usedSubnets(ctx, n.dockerClient)
+	}
+
+	subnet, err := n.subnetPool.allocate(used, create, listUsed)
+	if err != nil {
+		return types.NetworkCreateResponse{}, "", err
+	}
+
+	return resp, subnet.String(), nil
+}
+
+// allocate creates a network with the first free subnet. If that fails because the subnet was taken by someone else
+// in the meantime, the used subnets are listed again and the next free one is tried, up to maxSubnetAttempts times.
+func (p *subnetPool) allocate(
+	used []*net.IPNet,
+	create func(subnet *net.IPNet) error,
+	listUsed func() ([]*net.IPNet, error),
+) (*net.IPNet, error) {
+	var err error
+
+	for attempt := 0; attempt < maxSubnetAttempts; attempt++ {
+		subnet := p.next(used)
+		if subnet == nil {
+			return nil, fmt.Errorf("%w %s", ErrNoFreeSubnet, p.pool)
+		}
+
+		err = create(subnet)
+		if err == nil {
+			return subnet, nil
+		}
+
+		var usedErr error
+
+		used, usedErr = listUsed()
+		if usedErr != nil || !overlapsAny(subnet, used) {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+// next returns the first subnet of the pool that does not overlap with any of the used subnets.
+func (p *subnetPool) next(used []*net.IPNet) *net.IPNet {
+	poolPrefixLen, _ := p.pool.Mask.Size()
+	count := uint32(1) << (p.prefixLen - poolPrefixLen)
+	size := uint32(1) << (ipv4Bits - p.prefixLen)
+	start := binary.BigEndian.Uint32(p.pool.IP.To4())
+	mask := net.CIDRMask(p.prefixLen, ipv4Bits)
+
+	for i := uint32(0); i < count; i++ {
+		candidate := &net.IPNet{IP: uint32ToIP(start + i*size), Mask: mask}
+		if !overlapsAny(candidate, used) {
+			return candidate
+		}
+	}
+
+	return nil
+}
+
+func usedSubnets(ctx context.Context, dockerClient *client.Client) ([]*net.IPNet, error) {
+	networks, err := dockerClient.NetworkList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	var used []*net.IPNet
+
+	for _, network := range networks {
+		for _, config := range network.IPAM.Config {
+			if _, subnet, err := net.ParseCIDR(config.Subnet); err == nil {
+				used = append(used, subnet)
+			}
+		}
+	}
+
+	return used, nil
+}
+
+// withAutoSubnet sets the subnet of the IPv4 IPAM configuration. The gateway, IP range and auxiliary addresses
+// set on the builder are kept, IPv6 configurations are left untouched.
+func withAutoSubnet(configs []dockerNetwork.IPAMConfig, subnet string) []dockerNetwork.IPAMConfig {
+	var result []dockerNetwork.IPAMConfig
+
+	ipv4 := dockerNetwork.IPAMConfig{Subnet: subnet}
+
+	for _, config := range configs {
+		if isIPv6Config(config) {
+			result = append(result, config)
+
+			continue
+		}
+
+		if config.Gateway != "" {
+			ipv4.Gateway = config.Gateway
+		}
+
+		if config.IPRange != "" {
+			ipv4.IPRange = config.IPRange
+		}
+
+		for k, v := range config.AuxAddress {
+			if ipv4.AuxAddress == nil {
+				ipv4.AuxAddress = map[string]string{}
+			}
+
+			ipv4.AuxAddress[k] = v
+		}
+	}
+
+	return append(result, ipv4)
+}
+
+// isIPv6Config tells by the subnet, or if there is none by the gateway, whether the IPAM configuration is for IPv6.
+func isIPv6Config(config dockerNetwork.IPAMConfig) bool {
+	if _, subnet, err := net.ParseCIDR(config.Subnet); err == nil {
+		return subnet.IP.To4() == nil
+	}
+
+	ip := net.ParseIP(config.Gateway)
+
+	return ip != nil && ip.To4() == nil
+}
+
+func overlapsAny(subnet *net.IPNet, others []*net.IPNet) bool {
+	for _, other := range others {
+		if subnet.Contains(other.IP) || other.Contains(subnet.IP) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func uint32ToIP(v uint32) net.IP {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, v)
+
+	return ip
+}
+
+func firstIPv4Subnet(configs []dockerNetwork.IPAMConfig) string {
+	for _, config := range configs {
+		if _, subnet, err := net.ParseCIDR(config.Subnet); err == nil && subnet.IP.To4() != nil {
+			return config.Subnet
+		}
+	}
+
+	return ""
+}
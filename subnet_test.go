@@ -0,0 +1,275 @@
+package dockertest
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	dockerNetwork "github.com/docker/docker/api/types/network"
+)
+
+func TestNewSubnetPool(t *testing.T) {
+	tests := []struct {
+		name      string
+		cidr      string
+		prefixLen int
+		wantErr   bool
+	}{
+		{name: "default", cidr: "172.28.0.0/14", prefixLen: 24},
+		{name: "single subnet", cidr: "10.0.0.0/24", prefixLen: 24},
+		{name: "prefix smaller than pool", cidr: "10.0.0.0/24", prefixLen: 16, wantErr: true},
+		{name: "prefix too long", cidr: "10.0.0.0/24", prefixLen: 33, wantErr: true},
+		{name: "ipv6", cidr: "fd00::/48", prefixLen: 64, wantErr: true},
+		{name: "invalid cidr", cidr: "10.0.0.0", prefixLen: 24, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSubnetPool(tt.cidr, tt.prefixLen)
+			if tt.wantErr != (err != nil) {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if err != nil && !errors.Is(err, ErrInvalidSubnetPool) {
+				t.Fatalf("expected ErrInvalidSubnetPool, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSubnetPoolNext(t *testing.T) {
+	pool, err := newSubnetPool("10.0.0.0/22", 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		used []string
+		want string
+	}{
+		{name: "nothing used", want: "10.0.0.0/24"},
+		{name: "first used", used: []string{"10.0.0.0/24"}, want: "10.0.1.0/24"},
+		{name: "smaller subnet used", used: []string{"10.0.0.128/25"}, want: "10.0.1.0/24"},
+		{name: "larger subnet used", used: []string{"10.0.0.0/23"}, want: "10.0.2.0/24"},
+		{name: "unrelated used", used: []string{"192.168.0.0/16"}, want: "10.0.0.0/24"},
+		{name: "all used", used: []string{"10.0.0.0/8"}, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var used []*net.IPNet
+
+			for _, cidr := range tt.used {
+				_, subnet, err := net.ParseCIDR(cidr)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				used = append(used, subnet)
+			}
+
+			got := pool.next(used)
+			if tt.want == "" {
+				if got != nil {
+					t.Fatalf("expected no subnet, got %s", got)
+				}
+
+				return
+			}
+
+			if got == nil || got.String() != tt.want {
+				t.Fatalf("expected %s, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestResolveIP(t *testing.T) {
+	n := Network{NetworkName: "test", Subnet: "172.28.3.0/24"}
+
+	tests := []struct {
+		name      string
+		network   Network
+		ipAddress string
+		want      string
+		wantErr   error
+	}{
+		{name: "absolute", network: n, ipAddress: "10.1.2.3", want: "10.1.2.3"},
+		{name: "relative", network: n, ipAddress: ".10", want: "172.28.3.10"},
+		{name: "last host", network: n, ipAddress: ".254", want: "172.28.3.254"},
+		{name: "broadcast", network: n, ipAddress: ".255", wantErr: ErrHostOutOfRange},
+		{name: "beyond subnet", network: n, ipAddress: ".300", wantErr: ErrHostOutOfRange},
+		{name: "network address", network: n, ipAddress: ".0", wantErr: ErrHostOutOfRange},
+		{name: "negative", network: n, ipAddress: ".-1", wantErr: ErrHostOutOfRange},
+		{name: "unknown subnet", network: Network{NetworkName: "test"}, ipAddress: ".10", wantErr: ErrUnknownSubnet},
+		{name: "smallest subnet", network: Network{Subnet: "10.0.0.4/30"}, ipAddress: ".2", want: "10.0.0.6"},
+		{name: "point to point subnet", network: Network{Subnet: "10.0.0.4/31"}, ipAddress: ".1", wantErr: ErrHostOutOfRange},
+		{name: "single address subnet", network: Network{Subnet: "10.0.0.4/32"}, ipAddress: ".1", wantErr: ErrHostOutOfRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.network.resolveIP(tt.ipAddress)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+			}
+
+			if got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSubnetPoolAllocate(t *testing.T) {
+	pool, err := newSubnetPool("10.0.0.0/22", 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	errOverlap := errors.New("pool overlaps with other one on this address space")
+	errCreate := errors.New("driver failed")
+
+	taken := func(cidrs ...string) []*net.IPNet {
+		var subnets []*net.IPNet
+
+		for _, cidr := range cidrs {
+			_, subnet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			subnets = append(subnets, subnet)
+		}
+
+		return subnets
+	}
+
+	t.Run("first candidate taken in the meantime", func(t *testing.T) {
+		var tried []string
+
+		create := func(subnet *net.IPNet) error {
+			tried = append(tried, subnet.String())
+			if subnet.String() == "10.0.0.0/24" {
+				return errOverlap
+			}
+
+			return nil
+		}
+
+		listUsed := func() ([]*net.IPNet, error) {
+			return taken("10.0.0.0/24"), nil
+		}
+
+		subnet, err := pool.allocate(nil, create, listUsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if subnet.String() != "10.0.1.0/24" || len(tried) != 2 {
+			t.Fatalf("expected 10.0.1.0/24 after 2 attempts, got %s after %v", subnet, tried)
+		}
+	})
+
+	t.Run("other create error", func(t *testing.T) {
+		create := func(*net.IPNet) error { return errCreate }
+		listUsed := func() ([]*net.IPNet, error) { return nil, nil }
+
+		_, err := pool.allocate(nil, create, listUsed)
+		if !errors.Is(err, errCreate) {
+			t.Fatalf("expected the create error, got %v", err)
+		}
+	})
+
+	t.Run("attempts are limited", func(t *testing.T) {
+		// every candidate is taken by someone else right before it is created.
+		var used []*net.IPNet
+
+		create := func(subnet *net.IPNet) error {
+			used = append(used, subnet)
+
+			return errOverlap
+		}
+
+		listUsed := func() ([]*net.IPNet, error) {
+			return used, nil
+		}
+
+		largePool, err := newSubnetPool("10.0.0.0/16", 24)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = largePool.allocate(nil, create, listUsed)
+		if !errors.Is(err, errOverlap) {
+			t.Fatalf("expected the last create error, got %v", err)
+		}
+
+		if len(used) != maxSubnetAttempts {
+			t.Fatalf("expected %v attempts, got %v", maxSubnetAttempts, len(used))
+		}
+	})
+
+	t.Run("pool exhausted", func(t *testing.T) {
+		create := func(*net.IPNet) error { return errOverlap }
+		listUsed := func() ([]*net.IPNet, error) { return taken("10.0.0.0/8"), nil }
+
+		_, err := pool.allocate(nil, create, listUsed)
+		if !errors.Is(err, ErrNoFreeSubnet) {
+			t.Fatalf("expected ErrNoFreeSubnet, got %v", err)
+		}
+	})
+}
+
+func TestWithAutoSubnet(t *testing.T) {
+	tests := []struct {
+		name    string
+		configs []dockerNetwork.IPAMConfig
+		want    []dockerNetwork.IPAMConfig
+	}{
+		{
+			name: "no config",
+			want: []dockerNetwork.IPAMConfig{{Subnet: "172.28.0.0/24"}},
+		},
+		{
+			name: "ipv4 subnet replaced",
+			configs: []dockerNetwork.IPAMConfig{
+				{Subnet: "10.0.0.0/24", Gateway: "10.0.0.1", IPRange: "10.0.0.128/25"},
+			},
+			want: []dockerNetwork.IPAMConfig{
+				{Subnet: "172.28.0.0/24", Gateway: "10.0.0.1", IPRange: "10.0.0.128/25"},
+			},
+		},
+		{
+			name: "gateway and aux address kept",
+			configs: []dockerNetwork.IPAMConfig{
+				{Gateway: "172.28.0.1"},
+				{AuxAddress: map[string]string{"host": "172.28.0.5"}},
+			},
+			want: []dockerNetwork.IPAMConfig{
+				{Subnet: "172.28.0.0/24", Gateway: "172.28.0.1", AuxAddress: map[string]string{"host": "172.28.0.5"}},
+			},
+		},
+		{
+			name: "ipv6 untouched",
+			configs: []dockerNetwork.IPAMConfig{
+				{Subnet: "fd00:dead:beef::/48", Gateway: "fd00:dead:beef::1"},
+			},
+			want: []dockerNetwork.IPAMConfig{
+				{Subnet: "fd00:dead:beef::/48", Gateway: "fd00:dead:beef::1"},
+				{Subnet: "172.28.0.0/24"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := withAutoSubnet(tt.configs, "172.28.0.0/24")
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"reflect"
 	"runtime"
 	"strings"
@@ -16,16 +17,218 @@ import (
 
 var ErrClosedWithoutFinding = errors.New("log stream closed without finding")
 
+// ErrContainerExitTimeout is returned if a container did not exit in time.
+var ErrContainerExitTimeout = errors.New("timeout - container did not exit")
+
+// ErrContainerExitCode is returned if a container was expected to exit successfully, but exited with another code.
+var ErrContainerExitCode = errors.New("container exited with non-zero exit code")
+
+// ErrKilledAfterTimeout is returned from WaitExit if the container did not exit in time and was killed.
+var ErrKilledAfterTimeout = errors.New("container did not exit in time and was killed")
+
+// ErrContainerExited is returned from waits for a condition that cannot be met anymore, since the container exited.
+var ErrContainerExited = errors.New("container exited")
+
 var pollingPause = 1000 * time.Millisecond
 
+const waitErrorLogTailLines = 20
+
+// WaitError is returned if waiting for a container failed. It carries the diagnostics needed to explain
+// the failure, so the error message alone tells what went wrong, for example in a CI log.
+type WaitError struct {
+	Container string
+	Condition Condition
+	// Elapsed is the time waited until the wait failed.
+	Elapsed time.Duration
+	Err     error
+	// LogTail contains the last lines of the containers log output.
+	LogTail string
+	// HealthLog contains the last health check results, it is empty if the container has no health check.
+	HealthLog string
+	// State is the state of the container when the wait failed, it is nil if the container could not be inspected.
+	State *ContainerState
+}
+
+func (e *WaitError) Error() string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("%s: %v", e.headline(), e.Err))
+
+	if e.State != nil {
+		sb.WriteString(fmt.Sprintf("\n------ state of '%s': %s", e.Container, e.State))
+	}
+
+	if e.HealthLog != "" {
+		sb.WriteString(fmt.Sprintf("\n------ last health checks of '%s':\n%s",
+			e.Container, strings.TrimRight(e.HealthLog, "\n")))
+	}
+
+	if e.LogTail != "" {
+		sb.WriteString(fmt.Sprintf("\n------ last log lines of '%s':\n%s", e.Container, strings.TrimRight(e.LogTail, "\n")))
+	}
+
+	return sb.String()
+}
+
+func (e *WaitError) Unwrap() error {
+	return e.Err
+}
+
+// headline tells what happened to the container, depending on the cause of the failed wait.
+func (e *WaitError) headline() string {
+	elapsed := e.Elapsed.Round(time.Millisecond)
+
+	switch {
+	case errors.Is(e.Err, ErrOOMKilled):
+		return fmt.Sprintf("container '%s' exited OOM after %s, expected %s", e.Container, elapsed, e.Condition)
+	case errors.Is(e.Err, ErrContainerExited), errors.Is(e.Err, ErrContainerExitCode):
+		return fmt.Sprintf("container '%s' exited after %s, expected %s", e.Container, elapsed, e.Condition)
+	case isTimeout(e.Err):
+		return fmt.Sprintf("container '%s' not %s after %s", e.Container, e.Condition, elapsed)
+	default:
+		return fmt.Sprintf("container '%s' failed waiting for %s after %s", e.Container, e.Condition, elapsed)
+	}
+}
+
+// waitEndedError returns the timeout error if the context of the wait reached its deadline,
+// otherwise the cause of its cancellation.
+func waitEndedError(ctx context.Context, timeoutErr error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", timeoutErr, context.Cause(ctx))
+	}
+
+	return fmt.Errorf("wait cancelled: %w", context.Cause(ctx))
+}
+
+// isTimeout tells whether the wait ended at its deadline. A cancelled wait did not time out,
+// for example when a LogWatchdog cancelled the session.
+func isTimeout(err error) bool {
+	return errors.Is(err, ErrContainerStartTimeout) ||
+		errors.Is(err, ErrContainerExitTimeout) ||
+		errors.Is(err, ErrKilledAfterTimeout) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// newWaitError collects the diagnostics of the container. The context of the wait is done already in case
+// of a timeout, so a new one is used.
+func newWaitError(c *Container, condition Condition, started time.Time, err error) *WaitError {
+	waitErr := &WaitError{
+		Container: c.Name,
+		Condition: condition,
+		Elapsed:   time.Since(started),
+		Err:       err,
+		LogTail:   containerLogTail(c.dockerClient, c, waitErrorLogTailLines),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cleanerTimeout)
+	defer cancel()
+
+	state, stateErr := c.State(ctx)
+	if stateErr != nil {
+		return waitErr
+	}
+
+	waitErr.State = &state
+
+	if state.Health != "" {
+		healthLog, healthErr := getContainerHealthCheckLog(ctx, c.dockerClient, c)
+		if healthErr == nil {
+			waitErr.HealthLog = string(healthLog)
+		}
+	}
+
+	return waitErr
+}
+
+// WaitHealthy blocks until the containers health check reports healthy.
+// If the context reaches its deadline before, a *WaitError caused by ErrContainerStartTimeout is returned,
+// if it is cancelled, the cause is the cause of the cancellation.
+// If the container exits before, the cause is ErrContainerExited, or ErrOOMKilled if it ran out of memory.
+func (c Container) WaitHealthy(ctx context.Context) error {
+	started := time.Now()
+
+	err := waitForRunningContainer(ctx, containerIsHealthy, &c)
+	if err != nil {
+		return newWaitError(&c, ConditionHealthy(), started, err)
+	}
+
+	return nil
+}
+
+// WaitLog blocks until the search string was found in the containers log output.
+// If it fails, a *WaitError caused by ErrClosedWithoutFinding is returned, its LogTail shows the last lines
+// of the output. If the container exited without logging the search string, the cause matches
+// ErrContainerExited or ErrOOMKilled as well.
+func (c Container) WaitLog(ctx context.Context, search string) error {
+	started := time.Now()
+
+	err := waitForContainerLog(ctx, search, &c)
+	if err != nil {
+		return newWaitError(&c, ConditionLogContains(search), started, fmt.Errorf("error parsing log: %w", err))
+	}
+
+	return nil
+}
+
+// WaitExit blocks until the container has exited. If the context is done before, the container is killed
+// and ErrKilledAfterTimeout is returned, joined with the error of killing it, if that failed too.
+// If the container was killed because it ran out of memory, ErrOOMKilled is returned.
+// The errors are returned as cause of a *WaitError.
+func (c Container) WaitExit(ctx context.Context) error {
+	started := time.Now()
+
+	err := c.waitExit(ctx)
+	if err != nil {
+		return newWaitError(&c, Condition{kind: conditionExited}, started, err)
+	}
+
+	return nil
+}
+
+func (c Container) waitExit(ctx context.Context) error {
+	if waitForContainer(ctx, containerHasFadeAway, c.dockerClient, c.containerID, c.logger) {
+		return c.oomKilledError(ctx)
+	}
+
+	err := c.dockerClient.ContainerKill(context.Background(), c.containerID, "kill")
+	if err != nil {
+		return errors.Join(ErrKilledAfterTimeout, fmt.Errorf("error killing container '%s': %w", c.Name, err))
+	}
+
+	return ErrKilledAfterTimeout
+}
+
 type waitForContainerFunc func(inspectResult types.ContainerJSON, inspectError error) bool
 
-func containerIsHealthy(inspectResult types.ContainerJSON, _ error) bool {
-	return inspectResult.State.Health.Status == "healthy"
+func containerIsHealthy(inspectResult types.ContainerJSON, inspectError error) bool {
+	state := containerState(inspectResult, inspectError)
+
+	return state != nil && state.Health != nil && state.Health.Status == "healthy"
+}
+
+func containerHasStarted(inspectResult types.ContainerJSON, inspectError error) bool {
+	state := containerState(inspectResult, inspectError)
+
+	return state != nil && state.Status != "created"
 }
 
 func containerHasFadeAway(inspectResult types.ContainerJSON, inspectError error) bool {
-	return client.IsErrNotFound(inspectError) || !inspectResult.State.Running
+	if client.IsErrNotFound(inspectError) {
+		return true
+	}
+
+	state := containerState(inspectResult, inspectError)
+
+	return state != nil && !state.Running
+}
+
+// containerState returns the state of a successful inspect, or nil.
+func containerState(inspectResult types.ContainerJSON, inspectError error) *types.ContainerState {
+	if inspectError != nil || inspectResult.ContainerJSONBase == nil {
+		return nil
+	}
+
+	return inspectResult.State
 }
 
 func waitForContainer(
@@ -33,12 +236,14 @@ func waitForContainer(
 	f waitForContainerFunc,
 	dockerClient *client.Client,
 	containerID string,
+	logger *slog.Logger,
 ) bool {
 	for {
 		select {
 		case <-ctx.Done():
 			funcName := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
-			fmt.Printf("waiting for '%s' timed out for container %v\n", funcName, containerID)
+			logger.Warn("waiting for container timed out",
+				operationAttr(funcName), slog.String("containerId", containerID))
 
 			return false
 		default:
@@ -52,14 +257,73 @@ func waitForContainer(
 	}
 }
 
-func waitForContainerLog(ctx context.Context, search string, dockerClient *client.Client, containerID string) error {
+// waitForRunningContainer is like waitForContainer, but it fails as soon as the container exited,
+// since it cannot meet the condition anymore.
+func waitForRunningContainer(ctx context.Context, f waitForContainerFunc, c *Container) error {
+	for {
+		select {
+		case <-ctx.Done():
+			funcName := runtime.FuncForPC(reflect.ValueOf(f).Pointer()).Name()
+			c.logger.Warn("waiting for container timed out",
+				operationAttr(funcName), containerAttrs(c.Name, c.containerID))
+
+			return waitEndedError(ctx, ErrContainerStartTimeout)
+		default:
+			inspectResult, err := c.dockerClient.ContainerInspect(ctx, c.containerID)
+			if f(inspectResult, err) {
+				return nil
+			}
+
+			state := containerState(inspectResult, err)
+			if state != nil && (state.Status == string(StatusExited) || state.Status == string(StatusDead)) {
+				return exitedError(c.Name, state.OOMKilled, state.ExitCode)
+			}
+
+			time.Sleep(pollingPause)
+		}
+	}
+}
+
+// exitedError returns ErrOOMKilled if the container ran out of memory, ErrContainerExited otherwise.
+func exitedError(name string, oomKilled bool, exitCode int) error {
+	if oomKilled {
+		return fmt.Errorf("%w: '%s' exited with code %v", ErrOOMKilled, name, exitCode)
+	}
+
+	return fmt.Errorf("%w: '%s' exited with code %v", ErrContainerExited, name, exitCode)
+}
+
+func waitForSuccessfulExit(ctx context.Context, dockerClient *client.Client, logger *slog.Logger, c *Container) error {
+	if !waitForContainer(ctx, containerHasFadeAway, dockerClient, c.containerID, logger) {
+		return waitEndedError(ctx, ErrContainerExitTimeout)
+	}
+
+	inspectResult, err := dockerClient.ContainerInspect(ctx, c.containerID)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInspectingContainer, err)
+	}
+
+	if inspectResult.State.OOMKilled {
+		return fmt.Errorf("%w: '%s' exited with code %v", ErrOOMKilled, c.Name, inspectResult.State.ExitCode)
+	}
+
+	if inspectResult.State.ExitCode != 0 {
+		return fmt.Errorf("%w %v", ErrContainerExitCode, inspectResult.State.ExitCode)
+	}
+
+	return nil
+}
+
+// waitForContainerLog follows the log until the search string was found. The log stream ends when the container
+// stops, the error tells then whether it exited.
+func waitForContainerLog(ctx context.Context, search string, c *Container) error {
 	var logOpts = types.ContainerLogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
 		Follow:     true,
 	}
 
-	reader, err := dockerClient.ContainerLogs(ctx, containerID, logOpts)
+	reader, err := c.dockerClient.ContainerLogs(ctx, c.containerID, logOpts)
 	if err != nil {
 		return err
 	}
@@ -68,18 +332,35 @@ func waitForContainerLog(ctx context.Context, search string, dockerClient *clien
 		_ = reader.Close()
 	}()
 
-	var (
-		buffer  = strings.Builder{}
-		scanner = bufio.NewScanner(reader)
-	)
+	scanner := bufio.NewScanner(reader)
 
 	for scanner.Scan() {
 		if strings.Contains(scanner.Text(), search) {
 			return nil
-		} else {
-			buffer.WriteString(scanner.Text() + "\n")
 		}
 	}
 
-	return fmt.Errorf("%w '%s' (output: %s)", ErrClosedWithoutFinding, search, buffer.String())
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w '%s': %w", ErrClosedWithoutFinding, search, context.Cause(ctx))
+	}
+
+	state, err := c.State(ctx)
+	if err == nil && (state.Status == StatusExited || state.Status == StatusDead) {
+		exitErr := exitedError(c.Name, state.OOMKilled, state.ExitCode)
+
+		return fmt.Errorf("%w '%s': %w", ErrClosedWithoutFinding, search, exitErr)
+	}
+
+	return fmt.Errorf("%w '%s'", ErrClosedWithoutFinding, search)
+}
+
+// oomKilledError returns ErrOOMKilled if the exited container was killed because it ran out of memory.
+func (c Container) oomKilledError(ctx context.Context) error {
+	state, err := c.State(ctx)
+	if err != nil || !state.OOMKilled {
+		// a removed container cannot be inspected anymore, it exited as expected.
+		return nil
+	}
+
+	return fmt.Errorf("%w: '%s' exited with code %v", ErrOOMKilled, c.Name, state.ExitCode)
 }
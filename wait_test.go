@@ -0,0 +1,113 @@
+package dockertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/errdefs"
+)
+
+func TestWaitForContainerFuncs(t *testing.T) {
+	inspect := func(state *types.ContainerState) types.ContainerJSON {
+		return types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{State: state}}
+	}
+
+	running := inspect(&types.ContainerState{Status: "running", Running: true})
+	exited := inspect(&types.ContainerState{Status: "exited"})
+	healthy := inspect(&types.ContainerState{Status: "running", Running: true, Health: &types.Health{Status: "healthy"}})
+	errDaemon := errors.New("daemon unavailable")
+
+	tests := []struct {
+		name          string
+		inspectResult types.ContainerJSON
+		inspectError  error
+		healthy       bool
+		started       bool
+		fadeAway      bool
+	}{
+		{name: "running", inspectResult: running, started: true},
+		{name: "healthy", inspectResult: healthy, healthy: true, started: true},
+		{name: "exited", inspectResult: exited, started: true, fadeAway: true},
+		{name: "created", inspectResult: inspect(&types.ContainerState{Status: "created"}), fadeAway: true},
+		{name: "not found", inspectError: errdefs.NotFound(errDaemon), fadeAway: true},
+		{name: "inspect error", inspectError: errDaemon},
+		{name: "no state", inspectResult: inspect(nil)},
+		{name: "no base"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containerIsHealthy(tt.inspectResult, tt.inspectError); got != tt.healthy {
+				t.Fatalf("containerIsHealthy: expected %v, got %v", tt.healthy, got)
+			}
+
+			if got := containerHasStarted(tt.inspectResult, tt.inspectError); got != tt.started {
+				t.Fatalf("containerHasStarted: expected %v, got %v", tt.started, got)
+			}
+
+			if got := containerHasFadeAway(tt.inspectResult, tt.inspectError); got != tt.fadeAway {
+				t.Fatalf("containerHasFadeAway: expected %v, got %v", tt.fadeAway, got)
+			}
+		})
+	}
+}
+
+func TestWaitErrorHeadline(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition Condition
+		err       error
+		want      string
+	}{
+		{
+			name:      "timeout",
+			condition: ConditionHealthy(),
+			err:       fmt.Errorf("%w: %w", ErrContainerStartTimeout, context.DeadlineExceeded),
+			want:      "container 'db' not healthy after 2s: ",
+		},
+		{
+			name:      "cancelled",
+			condition: ConditionHealthy(),
+			err:       fmt.Errorf("%w: %w", context.Canceled, errors.New("panic found in log")),
+			want:      "container 'db' failed waiting for healthy after 2s: ",
+		},
+		{
+			name:      "killed after timeout",
+			condition: Condition{kind: conditionExited},
+			err:       ErrKilledAfterTimeout,
+			want:      "container 'db' not exited after 2s: ",
+		},
+		{
+			name:      "out of memory",
+			condition: Condition{kind: conditionExited},
+			err:       exitedError("db", true, 137),
+			want:      "container 'db' exited OOM after 2s, expected exited: ",
+		},
+		{
+			name:      "exited",
+			condition: ConditionHealthy(),
+			err:       exitedError("db", false, 1),
+			want:      "container 'db' exited after 2s, expected healthy: ",
+		},
+		{
+			name:      "log stream closed",
+			condition: ConditionLogContains("ready"),
+			err:       fmt.Errorf("%w 'ready'", ErrClosedWithoutFinding),
+			want:      "container 'db' failed waiting for log contains 'ready' after 2s: ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := &WaitError{Container: "db", Condition: tt.condition, Elapsed: 2 * time.Second, Err: tt.err}
+			if got := err.Error(); !strings.HasPrefix(got, tt.want) {
+				t.Fatalf("expected '%s' to start with '%s'", got, tt.want)
+			}
+		})
+	}
+}